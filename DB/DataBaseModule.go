@@ -6,65 +6,103 @@ import (
 	"errors"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/team-zf/framework/config"
 	"github.com/team-zf/framework/logger"
 	"github.com/team-zf/framework/modules"
 	"github.com/team-zf/framework/utils/threads"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
 type DataBaseModule struct {
 	name         string
+	depends      []string
 	dsn          string
+	maxOpenNum   int           // 最大连接数
+	maxIdleNum   int           // 最大空闲连接数
+	maxLifetime  time.Duration // 连接的最长使用时间
+	interval     time.Duration // 缓存写入数据库的间隔
+	queueSize    int           // 消息信道的长度
 	db           *sql.DB
 	thgo         *threads.ThreadGo
 	chanList     chan []IDataBaseMessage     // 消息信通
+	chanLock     sync.RWMutex                // 保护chanList的关闭
+	stopped      bool                        // 已停止, 不再接收消息
+	stopping     chan struct{}               // 开始停止时关闭, 唤醒等待放入消息的协程
+	flushList    chan chan struct{}          // 立刻保存的请求
 	cacheList    map[string]IDataBaseMessage // 缓存要更新的数据
+	degradeCount int64                       // 连续保存失败多少次后报告为异常
+	failCount    int64                       // 连续保存失败的次数
+	cacheCount   int64                       // 缓存中的数据条数
 	requestCount int64                       // 收到的请求总数
 	saveCount    int64                       // 保存的总数
 }
 
-func (e *DataBaseModule) Init() {
+func (e *DataBaseModule) Init() error {
 	db, err := sql.Open("mysql", e.dsn)
 	if err != nil {
-		panic(fmt.Sprintf("Mysql连接失败, 错误原因: %+v", err))
+		return fmt.Errorf("Mysql连接失败, 错误原因: %+v", err)
 	}
-	db.SetMaxOpenConns(100)
-	db.SetMaxIdleConns(50)
-	db.SetConnMaxLifetime(600 * time.Second)
+	db.SetMaxOpenConns(e.maxOpenNum)
+	db.SetMaxIdleConns(e.maxIdleNum)
+	db.SetConnMaxLifetime(e.maxLifetime)
 	if err = db.Ping(); err != nil {
-		panic(fmt.Sprintf("Mysql尝Ping失败, 错误原因: %+v", err))
+		db.Close()
+		return fmt.Errorf("Mysql尝Ping失败, 错误原因: %+v", err)
 	}
 	e.db = db
-	e.chanList = make(chan []IDataBaseMessage, 1024)
+	e.chanList = make(chan []IDataBaseMessage, e.queueSize)
+	e.stopping = make(chan struct{})
+	e.flushList = make(chan chan struct{})
 	e.cacheList = make(map[string]IDataBaseMessage)
+	return nil
 }
 
-func (e *DataBaseModule) Start() {
+func (e *DataBaseModule) Start() error {
 	e.thgo.Go(func(ctx context.Context) {
 		logger.Notice("%s启动", e.name)
 		e.Handle()
 	})
+	return nil
 }
 
-func (e *DataBaseModule) Stop() {
+func (e *DataBaseModule) Stop() error {
+	// 先唤醒因信道已满等待的AddMsg, 它们持有读锁, 否则这里拿不到锁
+	close(e.stopping)
+	// 先等缓存写完再关闭连接
+	e.chanLock.Lock()
+	e.stopped = true
 	close(e.chanList)
-	e.db.Close()
+	e.chanLock.Unlock()
 	e.thgo.CloseWait()
+	err := e.db.Close()
 	logger.Notice("%s已停止", e.name)
+	return err
 }
 
-func (e *DataBaseModule) PrintStatus() string {
-	return fmt.Sprintf(
-		"\r\n\t\t%s的状态:\t%d/%d/%d\t(Cache/Save/Request)",
-		e.name,
-		len(e.cacheList),
-		atomic.LoadInt64(&e.saveCount),
-		atomic.LoadInt64(&e.requestCount))
+// Release 初始化后没有启动时关闭连接池
+func (e *DataBaseModule) Release() error {
+	return e.db.Close()
+}
+
+func (e *DataBaseModule) Name() string {
+	return e.name
+}
+
+func (e *DataBaseModule) DependsOn() []string {
+	return e.depends
+}
+
+func (e *DataBaseModule) Status() *modules.ModuleStatus {
+	return modules.NewModuleStatus(e.name).
+		Gauge("cacheCount", float64(atomic.LoadInt64(&e.cacheCount))).
+		Counter("saveCount", atomic.LoadInt64(&e.saveCount)).
+		Counter("requestCount", atomic.LoadInt64(&e.requestCount))
 }
 
 func (e *DataBaseModule) Handle() {
-	t := time.NewTicker(time.Second)
+	t := time.NewTicker(e.interval)
 	defer t.Stop()
 	for {
 		select {
@@ -73,11 +111,28 @@ func (e *DataBaseModule) Handle() {
 				for _, msg := range msgs {
 					e.cacheList[msg.GetDataKey()] = msg
 				}
+				atomic.StoreInt64(&e.cacheCount, int64(len(e.cacheList)))
+				metricCacheSize.Set(float64(len(e.cacheList)), e.name)
+				metricQueueDepth.Set(float64(len(e.chanList)), e.name)
 			} else {
 				e.Save()
 				return
 			}
+		case done := <-e.flushList:
+			// 先把信道中已有的消息放进缓存
+			for n := len(e.chanList); n > 0; n-- {
+				msgs, ok := <-e.chanList
+				if !ok {
+					break
+				}
+				for _, msg := range msgs {
+					e.cacheList[msg.GetDataKey()] = msg
+				}
+			}
+			e.Save()
+			close(done)
 		case <-t.C:
+			metricQueueDepth.Set(float64(len(e.chanList)), e.name)
 			e.Save()
 		}
 	}
@@ -89,6 +144,12 @@ func (e *DataBaseModule) Save() {
 	}
 
 	atomic.AddInt64(&e.saveCount, 1)
+	begin := time.Now()
+	metricSaves.Inc(e.name)
+	metricBatchSize.Observe(float64(len(e.cacheList)), e.name)
+	defer func() {
+		metricSaveDuration.Observe(time.Since(begin).Seconds(), e.name)
+	}()
 	if tx, err := e.db.Begin(); err == nil {
 		threads.Try(
 			func() {
@@ -98,21 +159,74 @@ func (e *DataBaseModule) Save() {
 						panic(errors.New(str))
 					}
 				}
-				tx.Commit()
+				if err = tx.Commit(); err != nil {
+					panic(fmt.Errorf("Commit Error: %+v", err))
+				}
+				atomic.StoreInt64(&e.failCount, 0)
 			},
 			func(err error) {
 				tx.Rollback()
+				metricRollbacks.Inc(e.name)
+				atomic.AddInt64(&e.failCount, 1)
 				logger.Error(err.Error())
 			},
 		)
+	} else {
+		metricRollbacks.Inc(e.name)
+		atomic.AddInt64(&e.failCount, 1)
+		logger.Error("%s开启事务失败, 原因: %+v", e.name, err)
 	}
 	e.cacheList = make(map[string]IDataBaseMessage)
+	atomic.StoreInt64(&e.cacheCount, 0)
+	metricCacheSize.Set(0, e.name)
+}
+
+// Health 连续保存失败时报告为异常
+func (e *DataBaseModule) Health() (string, string) {
+	if n := atomic.LoadInt64(&e.failCount); e.degradeCount > 0 && n >= e.degradeCount {
+		return modules.HealthDegraded, fmt.Sprintf("连续%d次保存失败", n)
+	}
+	return modules.HealthOK, ""
+}
+
+// Flush 立刻保存缓存中的数据, 保存完成后返回
+func (e *DataBaseModule) Flush() error {
+	done := make(chan struct{})
+	select {
+	case e.flushList <- done:
+	case <-e.thgo.Ctx.Done():
+		return fmt.Errorf("%s已停止", e.name)
+	}
+	<-done
+	return nil
 }
 
-func (e *DataBaseModule) AddMsg(msgs ...IDataBaseMessage) {
-	if len(msgs) > 0 {
+// AddMsg 把要保存的数据放入缓存, 模块已停止时返回错误
+// 关闭服务器时超时未排空的请求可能在停止之后才调用
+func (e *DataBaseModule) AddMsg(msgs ...IDataBaseMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	e.chanLock.RLock()
+	defer e.chanLock.RUnlock()
+	if e.stopped {
+		logger.Error("%s已停止, 丢弃%d条数据", e.name, len(msgs))
+		return fmt.Errorf("%s已停止", e.name)
+	}
+	// 信道有空间时直接放入, 满时等待, 开始停止后不再等待
+	select {
+	case e.chanList <- msgs:
 		atomic.AddInt64(&e.requestCount, 1)
-		e.chanList <- msgs
+		return nil
+	default:
+	}
+	select {
+	case e.chanList <- msgs:
+		atomic.AddInt64(&e.requestCount, 1)
+		return nil
+	case <-e.stopping:
+		logger.Error("%s正在停止, 丢弃%d条数据", e.name, len(msgs))
+		return fmt.Errorf("%s已停止", e.name)
 	}
 }
 
@@ -134,8 +248,14 @@ func (e *DataBaseModule) Exec(query string, args ...interface{}) (sql.Result, er
 
 func NewDataBaseModule(opts ...modules.ModOptions) *DataBaseModule {
 	result := &DataBaseModule{
-		name: "DataBase",
-		thgo: threads.NewThreadGo(),
+		name:         "DataBase",
+		thgo:         threads.NewThreadGo(),
+		maxOpenNum:   100,
+		maxIdleNum:   50,
+		maxLifetime:  600 * time.Second,
+		interval:     time.Second,
+		queueSize:    1024,
+		degradeCount: 3,
 	}
 	for _, opt := range opts {
 		opt(result)
@@ -149,8 +269,61 @@ func DataBaseSetName(v string) modules.ModOptions {
 	}
 }
 
+func DataBaseSetDependsOn(v ...string) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*DataBaseModule).depends = v
+	}
+}
+
 func DataBaseSetDsn(v string) modules.ModOptions {
 	return func(mod modules.IModule) {
 		mod.(*DataBaseModule).dsn = v
 	}
 }
+
+// 设置连续保存失败多少次后报告为异常, 为0时不报告
+func DataBaseSetDegradeCount(v int64) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*DataBaseModule).degradeCount = v
+	}
+}
+
+// 按配置文件中的MySql部分设置连接参数, 没有配置的字段保留模块的默认值
+func DataBaseSetConfig(v *config.MySqlConfig) modules.ModOptions {
+	return func(mod modules.IModule) {
+		if v == nil {
+			return
+		}
+		e := mod.(*DataBaseModule)
+		e.dsn = v.Dsn
+		if v.MaxOpenNum != nil {
+			e.maxOpenNum = *v.MaxOpenNum
+		}
+		if v.MaxIdleNum != nil {
+			e.maxIdleNum = *v.MaxIdleNum
+		}
+		if v.MaxLifetime != nil {
+			e.maxLifetime = time.Duration(*v.MaxLifetime) * time.Second
+		}
+	}
+}
+
+// 按配置文件中的DataBase部分设置缓存写入的参数
+func DataBaseSetFlushConfig(v *config.DataBaseConfig) modules.ModOptions {
+	return func(mod modules.IModule) {
+		if v == nil {
+			return
+		}
+		e := mod.(*DataBaseModule)
+		if v.FlushInterval > 0 {
+			e.interval = time.Duration(v.FlushInterval) * time.Millisecond
+		}
+		if v.QueueSize > 0 {
+			e.queueSize = v.QueueSize
+		}
+		e.degradeCount = int64(v.DegradeCount)
+		if e.degradeCount < 0 {
+			e.degradeCount = 0
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package DB
+
+import (
+	"testing"
+	"time"
+
+	"github.com/team-zf/framework/dal"
+)
+
+type testMessage struct{}
+
+func (e *testMessage) GetDataKey() string          { return "test" }
+func (e *testMessage) SaveDB(db dal.IConnDB) error { return nil }
+
+// 信道已满时开始停止, 等待中的AddMsg返回错误, 不会挡住停止
+func TestAddMsgWhileStopping(t *testing.T) {
+	mod := &DataBaseModule{
+		name:     "DB",
+		chanList: make(chan []IDataBaseMessage, 1),
+		stopping: make(chan struct{}),
+	}
+	if err := mod.AddMsg(&testMessage{}); err != nil {
+		t.Fatal(err)
+	}
+	result := make(chan error, 1)
+	go func() {
+		result <- mod.AddMsg(&testMessage{})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(mod.stopping)
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Fatal("停止时应该返回错误")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("停止时AddMsg没有返回")
+	}
+	// 停止时可以拿到写锁
+	mod.chanLock.Lock()
+	mod.stopped = true
+	mod.chanLock.Unlock()
+	if err := mod.AddMsg(&testMessage{}); err == nil {
+		t.Fatal("停止后应该返回错误")
+	}
+}
@@ -0,0 +1,31 @@
+package DB
+
+import (
+	"fmt"
+	"github.com/team-zf/framework/config"
+	"github.com/team-zf/framework/modules"
+)
+
+func init() {
+	modules.RegisterModuleType("database", newDataBaseModuleFromConfig)
+}
+
+// 先使用配置文件中MySql和DataBase部分的参数, 再用模块声明中的参数覆盖
+func newDataBaseModuleFromConfig(app modules.IApp, conf *config.ModuleConfig) (modules.IModule, error) {
+	appConf := app.GetConfig()
+	result := NewDataBaseModule(
+		DataBaseSetConfig(appConf.MySql),
+		DataBaseSetFlushConfig(appConf.DataBase),
+		DataBaseSetDependsOn(conf.DependsOn...),
+	)
+	if conf.Name != "" {
+		result.name = conf.Name
+	}
+	if conf.Dsn != "" {
+		result.dsn = conf.Dsn
+	}
+	if result.dsn == "" {
+		return nil, fmt.Errorf("%s没有配置Dsn", result.name)
+	}
+	return result, nil
+}
@@ -7,7 +7,8 @@ import (
 
 type IDataBaseModule interface {
 	modules.IModule
-	AddMsg(msgs ...IDataBaseMessage)
+	AddMsg(msgs ...IDataBaseMessage) error
+	Flush() error
 	GetDB() *sql.DB
 	QueryRow(query string, args ...interface{}) *sql.Row
 	Query(query string, args ...interface{}) (*sql.Rows, error)
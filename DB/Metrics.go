@@ -0,0 +1,14 @@
+package DB
+
+import (
+	"github.com/team-zf/framework/metrics"
+)
+
+var (
+	metricQueueDepth   = metrics.NewGauge("zf_db_queue_depth", "等待写入缓存的消息数", "module")
+	metricCacheSize    = metrics.NewGauge("zf_db_cache_size", "缓存中等待保存的数据条数", "module")
+	metricSaves        = metrics.NewCounter("zf_db_saves_total", "保存的总次数", "module")
+	metricRollbacks    = metrics.NewCounter("zf_db_rollbacks_total", "保存失败回滚的总次数", "module")
+	metricBatchSize    = metrics.NewHistogram("zf_db_save_batch_size", "每次保存的数据条数", []float64{1, 5, 10, 50, 100, 500, 1000, 5000}, "module")
+	metricSaveDuration = metrics.NewHistogram("zf_db_save_duration_seconds", "每次保存的耗时(秒)", nil, "module")
+)
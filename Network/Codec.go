@@ -0,0 +1,150 @@
+package Network
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ICodec 消息体的编码方式
+type ICodec interface {
+	// 名称, 客户端连接时用 ?codec=名称 选择
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	JsonCodec    ICodec = &jsonCodec{}
+	MsgPackCodec ICodec = &msgPackCodec{}
+
+	codecLock sync.RWMutex
+	codecs    = map[string]ICodec{
+		JsonCodec.Name():    JsonCodec,
+		MsgPackCodec.Name(): MsgPackCodec,
+	}
+)
+
+// RegisterCodec 注册自定义的编码方式, 名称不区分大小写
+func RegisterCodec(codec ICodec) {
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	codecs[strings.ToLower(codec.Name())] = codec
+}
+
+// GetCodec 按名称取得编码方式, 不存在时返回nil
+func GetCodec(name string) ICodec {
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+	return codecs[strings.ToLower(name)]
+}
+
+type jsonCodec struct{}
+
+func (e *jsonCodec) Name() string {
+	return "json"
+}
+
+func (e *jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (e *jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// 只解码cmd和seq字段, 用于没有在消息头中带cmd的消息
+type cmdOnly struct {
+	Cmd interface{} `json:"cmd"`
+	Seq interface{} `json:"seq"`
+}
+
+// 从消息体中取得cmd和seq, 没有seq时为0
+func decodeCmd(codec ICodec, body []byte) (uint32, uint32, error) {
+	var msg cmdOnly
+	if err := codec.Unmarshal(body, &msg); err != nil {
+		return 0, 0, err
+	}
+	if msg.Cmd == nil {
+		return 0, 0, fmt.Errorf("消息中没有cmd")
+	}
+	cmd, err := parseUint32(msg.Cmd)
+	if err != nil {
+		return 0, 0, err
+	}
+	var seq uint32
+	if msg.Seq != nil {
+		if seq, err = parseUint32(msg.Seq); err != nil {
+			return 0, 0, err
+		}
+	}
+	return cmd, seq, nil
+}
+
+// 取得cmd或seq的值, 支持各种编码方式解码出的整数, 浮点数和字符串
+func parseUint32(v interface{}) (uint32, error) {
+	var result float64
+	switch v := v.(type) {
+	case int:
+		result = float64(v)
+	case int8:
+		result = float64(v)
+	case int16:
+		result = float64(v)
+	case int32:
+		result = float64(v)
+	case int64:
+		result = float64(v)
+	case uint:
+		result = float64(v)
+	case uint8:
+		result = float64(v)
+	case uint16:
+		result = float64(v)
+	case uint32:
+		return v, nil
+	case uint64:
+		result = float64(v)
+	case float32:
+		result = float64(v)
+	case float64:
+		result = v
+	case json.Number:
+		n, err := strconv.ParseUint(string(v), 10, 32)
+		return uint32(n), err
+	case string:
+		n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 32)
+		return uint32(n), err
+	default:
+		return 0, fmt.Errorf("不是数字: %v", v)
+	}
+	if result < 0 || result > math.MaxUint32 || result != math.Trunc(result) {
+		return 0, fmt.Errorf("超出范围: %v", v)
+	}
+	return uint32(result), nil
+}
+
+// 取得要发送的数据中的cmd, 用于写入消息头
+func cmdOf(data interface{}) uint32 {
+	switch v := data.(type) {
+	case IRoute:
+		return v.GetCmd()
+	case *WebSocketResponse:
+		return v.Cmd
+	case *PushMessage:
+		return v.Cmd
+	case map[string]interface{}:
+		switch cmd := v["cmd"].(type) {
+		case uint32:
+			return cmd
+		case float64:
+			return uint32(cmd)
+		case int:
+			return uint32(cmd)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,168 @@
+package Network
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+type codecTestRoute struct {
+	WebSocketRoute
+	Name  string  `json:"name"`
+	Count int64   `json:"count"`
+	Rate  float64 `json:"rate"`
+	Tags  []string
+}
+
+func (e *codecTestRoute) Parse() {}
+
+func (e *codecTestRoute) Handle(agent *WebSocketAgent) uint32 {
+	return 0
+}
+
+func TestMsgPackRoundTrip(t *testing.T) {
+	src := &codecTestRoute{Name: "测试", Count: -70000, Rate: 0.5, Tags: []string{"a", "b"}}
+	src.Cmd = 1001
+	buff, err := MsgPackCodec.Marshal(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := &codecTestRoute{}
+	if err := MsgPackCodec.Unmarshal(buff, dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Cmd != src.Cmd || dst.Name != src.Name || dst.Count != src.Count || dst.Rate != src.Rate || len(dst.Tags) != 2 || dst.Tags[1] != "b" {
+		t.Fatalf("解码结果不一致: %+v", dst)
+	}
+}
+
+func TestUnmarshalFrameHeaderCmd(t *testing.T) {
+	handle := NewWebSocketRouteHandle()
+	handle.SetRoute(1001, &codecTestRoute{})
+	for _, codec := range []ICodec{JsonCodec, MsgPackCodec} {
+		for _, headerCmd := range []bool{true, false} {
+			// 消息头中带cmd时, 消息体中可以不带cmd
+			data := map[string]interface{}{"name": codec.Name()}
+			if !headerCmd {
+				data["cmd"] = 1001
+			}
+			body, err := codec.Marshal(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			frame := &Frame{Cmd: 1001, Body: body}
+			if headerCmd {
+				frame.Flags = FRAME_FLAG_CMD
+			}
+			buff, err := frame.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			route, err := handle.UnmarshalFrame(codec, buff)
+			if err != nil {
+				t.Fatal(codec.Name(), headerCmd, err)
+			}
+			result := route.(*codecTestRoute)
+			if result.GetCmd() != 1001 || result.Name != codec.Name() {
+				t.Fatalf("%s %v: %+v", codec.Name(), headerCmd, result)
+			}
+		}
+	}
+}
+
+// 值类型的编码方式, 包含slice字段, 不能作为map的键
+type sliceCodec struct {
+	prefix []byte
+}
+
+func (e sliceCodec) Name() string { return "slice" }
+
+func (e sliceCodec) Marshal(v interface{}) ([]byte, error) {
+	buff, err := JsonCodec.Marshal(v)
+	return append(append([]byte{}, e.prefix...), buff...), err
+}
+
+func (e sliceCodec) Unmarshal(data []byte, v interface{}) error {
+	return JsonCodec.Unmarshal(data[len(e.prefix):], v)
+}
+
+func TestPushCacheCodec(t *testing.T) {
+	handle := NewWebSocketRouteHandle()
+	agents := []*WebSocketAgent{
+		{RouteHandle: handle, codec: sliceCodec{[]byte("#")}, headerCmd: true},
+		{RouteHandle: handle, codec: sliceCodec{[]byte("#")}, headerCmd: true},
+		{RouteHandle: handle, codec: JsonCodec, headerCmd: true},
+	}
+	encoder := newPushEncoder(9, "a")
+	var buffs [][]byte
+	for _, agent := range agents {
+		buff, err := encoder.encode(agent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buffs = append(buffs, buff)
+	}
+	if len(encoder.cache) != 2 || &buffs[0][0] != &buffs[1][0] || string(buffs[0]) == string(buffs[2]) {
+		t.Fatalf("同样的编码方式应该只编码一次: %d", len(encoder.cache))
+	}
+}
+
+func TestMsgPackUnmarshalPlain(t *testing.T) {
+	buff, err := MsgPackCodec.Marshal(map[string]interface{}{"cmd": 1001, "list": []interface{}{"a", 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := MsgPackCodec.Unmarshal(buff, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["cmd"] != int64(1001) || m["list"].([]interface{})[1] != int64(2) {
+		t.Fatalf("解码结果不正确: %#v", m)
+	}
+
+	// 超出int64范围的uint64不能变成有精度损失的浮点数
+	var v interface{}
+	if err := MsgPackCodec.Unmarshal([]byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, &v); err == nil {
+		t.Fatalf("超出范围时应该返回错误: %v", v)
+	}
+	if err := MsgPackCodec.Unmarshal([]byte{0xcf, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, &v); err != nil || v != int64(math.MaxInt64) {
+		t.Fatalf("解码结果不正确: %v %v", v, err)
+	}
+}
+
+func TestParseUint32(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  uint32
+		ok    bool
+	}{
+		{float64(1001), 1001, true},
+		{int64(1001), 1001, true},
+		{uint64(1001), 1001, true},
+		{int(7), 7, true},
+		{uint8(7), 7, true},
+		{uint32(math.MaxUint32), math.MaxUint32, true},
+		{"1001", 1001, true},
+		{json.Number("1001"), 1001, true},
+		{int64(-1), 0, false},
+		{uint64(math.MaxUint32 + 1), 0, false},
+		{1.5, 0, false},
+		{"abc", 0, false},
+		{true, 0, false},
+	}
+	for _, test := range tests {
+		result, err := parseUint32(test.value)
+		if (err == nil) != test.ok || result != test.want {
+			t.Errorf("%T(%v): %d %v", test.value, test.value, result, err)
+		}
+	}
+}
+
+func TestDecodeFrameBadBody(t *testing.T) {
+	handle := NewWebSocketRouteHandle()
+	handle.SetRoute(1001, &codecTestRoute{})
+	frame := &Frame{Flags: FRAME_FLAG_CMD, Cmd: 1001, Body: []byte(`{"name": 1}`)}
+	if _, err := handle.DecodeFrame(JsonCodec, frame); err == nil {
+		t.Fatal("消息体格式错误时应该返回错误")
+	}
+}
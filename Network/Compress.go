@@ -0,0 +1,55 @@
+package Network
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// 消息体达到这个长度时压缩
+const COMPRESS_THRESHOLD = 1024
+
+var errInflateTooLarge = errors.New("解压后的长度超过限制")
+
+// 压缩后的消息体为deflate(RFC 1951)格式, 不带zlib或gzip的头
+var flateWriters = sync.Pool{
+	New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	},
+}
+
+func deflate(data []byte) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	w := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(w)
+	w.Reset(buffer)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// 解压, 最多读取limit+1字节, 超过limit时返回errInflateTooLarge
+// 不会把整个压缩炸弹解压到内存中
+func inflate(data []byte, limit int) ([]byte, error) {
+	if limit < 0 {
+		limit = 0
+	}
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	buff, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(buff) > limit {
+		return buff, errInflateTooLarge
+	}
+	return buff, nil
+}
@@ -0,0 +1,240 @@
+package Network
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// 握手使用P-256的ECDH协商密钥, 服务器用长期的Ed25519私钥对双方的临时公钥签名, 客户端用预先保存的服务器公钥校验
+// 客户端发送: 临时公钥(65字节); 服务器回应: 临时公钥(65字节) + 签名(64字节)
+// 共享密钥用HKDF-SHA256分别导出客户端到服务器和服务器到客户端的AES-256-GCM密钥, 两个方向的消息不能互相替换
+// 加密的消息: 消息头(FRAME_FLAG_ENCRYPT) + 12字节nonce(4字节0 + 8字节递增的计数) + 加密后的一条完整消息 + 16字节校验
+// 消息头作为附加数据参与校验, 长度和标志被修改时解密失败; 计数不是严格递增时视为重放或乱序, 断开连接
+const (
+	SESSION_NONCE_SIZE = 12
+	SESSION_TAG_SIZE   = 16
+	SESSION_OVERHEAD   = FRAME_HEADER_SIZE + SESSION_NONCE_SIZE + SESSION_TAG_SIZE // 加密后增加的长度
+
+	handshakePublicSize = 65 // P-256未压缩的公钥
+)
+
+var (
+	ErrHandshakeKey    = errors.New("握手的公钥不正确")
+	ErrHandshakeSign   = errors.New("握手的签名校验失败")
+	ErrHandshakeTwice  = errors.New("重复握手")
+	ErrNotHandshake    = errors.New("没有握手")
+	ErrNoSignKey       = errors.New("没有设置握手的签名私钥")
+	ErrDecryptFailed   = errors.New("消息校验失败")
+	ErrNonceReplay     = errors.New("消息的计数不是递增的")
+	ErrNonceExhausted  = errors.New("消息的计数已用完")
+	ErrNestedEncrypted = errors.New("加密的消息中不能再带握手或加密标志")
+
+	handshakeSignLabel = []byte("team-zf handshake")
+	sessionC2SLabel    = "team-zf c2s"
+	sessionS2CLabel    = "team-zf s2c"
+)
+
+// ParseSignKey 解析base64编码的32字节Ed25519种子, 作为服务器握手时签名的私钥
+// 客户端需要保存对应的公钥: ParseSignKey(...).Public()
+func ParseSignKey(s string) (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("签名私钥必须是%d字节, 当前为%d字节", ed25519.SeedSize, len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// HandshakeKey 握手时生成的临时密钥, 服务器和客户端各生成一个
+type HandshakeKey struct {
+	priv *ecdh.PrivateKey
+}
+
+func NewHandshakeKey() (*HandshakeKey, error) {
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &HandshakeKey{priv: priv}, nil
+}
+
+// Public 发送给对方的公钥
+func (e *HandshakeKey) Public() []byte {
+	return e.priv.PublicKey().Bytes()
+}
+
+// ServerSession 服务器用客户端的公钥计算出本次连接的密钥, 返回回应给客户端的内容
+func (e *HandshakeKey) ServerSession(peer []byte, signKey ed25519.PrivateKey) ([]byte, *SessionCipher, error) {
+	if signKey == nil {
+		return nil, nil, ErrNoSignKey
+	}
+	pub := e.Public()
+	keys, err := e.sessionKeys(peer, peer, pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	cipher, err := NewSessionCipher(keys[1], keys[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	sign := ed25519.Sign(signKey, handshakeSigned(peer, pub))
+	return append(pub, sign...), cipher, nil
+}
+
+// ClientSession 客户端校验服务器的回应, 计算出本次连接的密钥
+func (e *HandshakeKey) ClientSession(reply []byte, serverKey ed25519.PublicKey) (*SessionCipher, error) {
+	if len(reply) != handshakePublicSize+ed25519.SignatureSize || len(serverKey) != ed25519.PublicKeySize {
+		return nil, ErrHandshakeKey
+	}
+	pub, peer := e.Public(), reply[:handshakePublicSize]
+	if !ed25519.Verify(serverKey, handshakeSigned(pub, peer), reply[handshakePublicSize:]) {
+		return nil, ErrHandshakeSign
+	}
+	keys, err := e.sessionKeys(peer, pub, peer)
+	if err != nil {
+		return nil, err
+	}
+	return NewSessionCipher(keys[0], keys[1])
+}
+
+// 计算共享密钥, 导出客户端到服务器和服务器到客户端两个方向的密钥
+func (e *HandshakeKey) sessionKeys(peer, client, server []byte) ([2][]byte, error) {
+	var keys [2][]byte
+	pub, err := ecdh.P256().NewPublicKey(peer)
+	if err != nil {
+		return keys, ErrHandshakeKey
+	}
+	secret, err := e.priv.ECDH(pub)
+	if err != nil {
+		return keys, ErrHandshakeKey
+	}
+	salt := append(append([]byte{}, client...), server...)
+	for i, label := range []string{sessionC2SLabel, sessionS2CLabel} {
+		keys[i] = hkdfSHA256(secret, salt, []byte(label))
+	}
+	return keys, nil
+}
+
+// HKDF-SHA256(RFC 5869)导出32字节的密钥, 只需要一轮扩展
+func hkdfSHA256(secret, salt, info []byte) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)
+}
+
+// 签名的内容, 包含双方的临时公钥, 回应不能被用在其它连接上
+func handshakeSigned(client, server []byte) []byte {
+	buff := make([]byte, 0, len(handshakeSignLabel)+len(client)+len(server))
+	buff = append(buff, handshakeSignLabel...)
+	buff = append(buff, client...)
+	return append(buff, server...)
+}
+
+// SessionCipher 加密和解密一个连接的消息, 发送和接收各用一个密钥和计数
+// 可以同时在多个协程中使用, 但发送的顺序要与加密的顺序一致, 否则对方会因计数不递增断开
+type SessionCipher struct {
+	sealLock  sync.Mutex
+	seal      cipher.AEAD
+	sealCount uint64 // 最后发送的计数
+	openLock  sync.Mutex
+	open      cipher.AEAD
+	openCount uint64 // 最后收到的计数
+}
+
+// NewSessionCipher sealKey用于加密发送的消息, openKey用于解密收到的消息, 32字节时使用AES-256
+func NewSessionCipher(sealKey, openKey []byte) (*SessionCipher, error) {
+	seal, err := newSessionAEAD(sealKey)
+	if err != nil {
+		return nil, err
+	}
+	open, err := newSessionAEAD(openKey)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionCipher{seal: seal, open: open}, nil
+}
+
+func newSessionAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal 把一条完整的消息加密成带FRAME_FLAG_ENCRYPT的消息
+func (e *SessionCipher) Seal(inner []byte) ([]byte, error) {
+	size := FRAME_HEADER_SIZE + SESSION_NONCE_SIZE + len(inner) + SESSION_TAG_SIZE
+	if uint32(size) > FRAME_MAX_SIZE {
+		return nil, &ErrFrameTooLarge{Size: uint32(size), MaxSize: FRAME_MAX_SIZE}
+	}
+	buff := make([]byte, FRAME_HEADER_SIZE+SESSION_NONCE_SIZE, size)
+	putFrameHeader(buff, uint32(size), FRAME_FLAG_ENCRYPT)
+	nonce := buff[FRAME_HEADER_SIZE:]
+
+	e.sealLock.Lock()
+	defer e.sealLock.Unlock()
+	if e.sealCount == ^uint64(0) {
+		return nil, ErrNonceExhausted
+	}
+	e.sealCount++
+	binary.BigEndian.PutUint64(nonce[4:], e.sealCount)
+	return e.seal.Seal(buff, nonce, inner, buff[:FRAME_HEADER_SIZE]), nil
+}
+
+// Open 解密带FRAME_FLAG_ENCRYPT的消息, 返回里面的一条完整消息
+// 计数不大于上一条消息时返回ErrNonceReplay, 调用者应断开连接
+func (e *SessionCipher) Open(outer []byte) ([]byte, error) {
+	if len(outer) < FRAME_HEADER_SIZE+SESSION_NONCE_SIZE+SESSION_TAG_SIZE {
+		return nil, ErrDecryptFailed
+	}
+	pos := FRAME_HEADER_SIZE + SESSION_NONCE_SIZE
+	nonce := outer[FRAME_HEADER_SIZE:pos]
+	if binary.BigEndian.Uint32(nonce) != 0 {
+		return nil, ErrDecryptFailed
+	}
+	e.openLock.Lock()
+	defer e.openLock.Unlock()
+	inner, err := e.open.Open(nil, nonce, outer[pos:], outer[:FRAME_HEADER_SIZE])
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+	// 校验通过后才检查计数, 伪造的消息不能推进计数
+	count := binary.BigEndian.Uint64(nonce[4:])
+	if count <= e.openCount {
+		return nil, ErrNonceReplay
+	}
+	e.openCount = count
+	return inner, nil
+}
+
+// 解密后解析里面的消息
+func (e *SessionCipher) openFrame(outer []byte, maxSize uint32) (*Frame, error) {
+	inner, err := e.Open(outer)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := ParseFrame(inner, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	if frame.Flags&frameFlagsAlone != 0 {
+		return nil, ErrNestedEncrypted
+	}
+	return frame, nil
+}
@@ -0,0 +1,88 @@
+package Network
+
+import (
+	"fmt"
+	"github.com/team-zf/framework/config"
+	"github.com/team-zf/framework/modules"
+)
+
+func init() {
+	modules.RegisterModuleType("http", newHttpModuleFromConfig)
+	modules.RegisterModuleType("websocket", newWebSocketModuleFromConfig)
+}
+
+// 先使用配置文件中Http部分的参数, 再用模块声明中的参数覆盖
+// Settings: Timeout 请求超时, Codec 默认的编码方式, RateLimit 请求频率限制
+func newHttpModuleFromConfig(app modules.IApp, conf *config.ModuleConfig) (modules.IModule, error) {
+	result := NewHttpModule(
+		HttpSetConfig(app.GetConfig().Http),
+		HttpSetDependsOn(conf.DependsOn...),
+	)
+	if conf.Name != "" {
+		result.name = conf.Name
+	}
+	if conf.Addr != "" {
+		result.ipPort = conf.Addr
+	}
+	result.timeout = conf.Settings.GetDuration("Timeout", result.timeout)
+	if err := bindRateLimiter(conf.Settings, &result.limiter); err != nil {
+		return nil, err
+	}
+	if name := conf.Settings.GetString("Codec", ""); name != "" {
+		if result.codec = GetCodec(name); result.codec == nil {
+			return nil, fmt.Errorf("不支持的编码方式: %s", name)
+		}
+	}
+	return result, nil
+}
+
+// 先使用配置文件中WebSocket部分的参数, 再用模块声明中的参数覆盖
+// Settings: WriteTimeout 写超时, HeartbeatTimeout 心跳超时, PingInterval 发送ping的间隔, PongTimeout 等待回应的时间, Codec 默认的编码方式,
+// CompressThreshold 消息体达到多少字节时压缩, Encrypt 是否要求客户端先握手, SignKey 握手时签名的私钥, RateLimit 请求频率限制
+func newWebSocketModuleFromConfig(app modules.IApp, conf *config.ModuleConfig) (modules.IModule, error) {
+	result := NewWebSocketModule(
+		WebSocketSetConfig(app.GetConfig().WebSocket),
+		WebSocketSetDependsOn(conf.DependsOn...),
+	)
+	if conf.Name != "" {
+		result.name = conf.Name
+	}
+	if conf.Addr != "" {
+		result.addr = conf.Addr
+	}
+	result.writeTimeout = conf.Settings.GetDuration("WriteTimeout", result.writeTimeout)
+	result.idleTimeout = conf.Settings.GetDuration("HeartbeatTimeout", result.idleTimeout)
+	result.pingInterval = conf.Settings.GetDuration("PingInterval", result.pingInterval)
+	result.pongTimeout = conf.Settings.GetDuration("PongTimeout", result.pongTimeout)
+	result.compress = conf.Settings.GetInt("CompressThreshold", result.compress)
+	result.encrypt = conf.Settings.GetBool("Encrypt", result.encrypt)
+	if s := conf.Settings.GetString("SignKey", ""); s != "" {
+		key, err := ParseSignKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("SignKey格式错误: %v", err)
+		}
+		result.signKey = key
+	}
+	if err := bindRateLimiter(conf.Settings, &result.limiter); err != nil {
+		return nil, err
+	}
+	if name := conf.Settings.GetString("Codec", ""); name != "" {
+		if result.codec = GetCodec(name); result.codec == nil {
+			return nil, fmt.Errorf("不支持的编码方式: %s", name)
+		}
+	}
+	return result, nil
+}
+
+// Settings中有RateLimit时代替配置文件中的限制, 格式与配置文件相同
+func bindRateLimiter(settings config.Settings, limiter **RateLimiter) error {
+	if !settings.Has("RateLimit") {
+		return nil
+	}
+	conf := &config.RateLimitConfig{}
+	if err := settings.Bind("RateLimit", conf); err != nil {
+		return fmt.Errorf("RateLimit: %v", err)
+	}
+	*limiter = NewRateLimiter(conf)
+	return nil
+}
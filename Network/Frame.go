@@ -0,0 +1,131 @@
+package Network
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// 消息头为4字节的小端整数, 与ROUTEHANDLE_HEADER异或后:
+// 低24位为消息的总长度(包含消息头), 高8位为标志
+const (
+	FRAME_LEN_MASK uint32 = 0x00FFFFFF
+	FRAME_MAX_SIZE uint32 = FRAME_LEN_MASK
+
+	FRAME_FLAG_CMD       byte = 0x01 // 消息头后带4字节小端的cmd
+	FRAME_FLAG_COMPRESS  byte = 0x02 // 消息体(cmd之后的部分)使用deflate压缩
+	FRAME_FLAG_HANDSHAKE byte = 0x04 // 握手消息, 消息体为公钥
+	FRAME_FLAG_ENCRYPT   byte = 0x08 // 加密的消息, 消息体为加密后的一条完整消息
+	FRAME_FLAG_SEQ       byte = 0x10 // cmd之后带4字节小端的序号
+
+	frameFlagsKnown = FRAME_FLAG_CMD | FRAME_FLAG_COMPRESS | FRAME_FLAG_HANDSHAKE | FRAME_FLAG_ENCRYPT | FRAME_FLAG_SEQ
+	// 只能单独使用的标志, 消息体由连接处理
+	frameFlagsAlone = FRAME_FLAG_HANDSHAKE | FRAME_FLAG_ENCRYPT
+)
+
+// Frame 一条完整的消息
+type Frame struct {
+	Flags byte
+	Cmd   uint32 // Flags带FRAME_FLAG_CMD时有效
+	Seq   uint32 // Flags带FRAME_FLAG_SEQ时有效
+	Body  []byte // 解压后的消息体
+}
+
+// ParseFrame 解析一条完整的消息, buff包含消息头
+// 压缩的消息体会被解压, 解压后的总长度超过maxSize时返回ErrFrameTooLarge
+func ParseFrame(buff []byte, maxSize uint32) (*Frame, error) {
+	if len(buff) < FRAME_HEADER_SIZE {
+		return nil, ErrFrameTooSmall
+	}
+	if uint32(len(buff)) > maxSize {
+		return nil, &ErrFrameTooLarge{Size: uint32(len(buff)), MaxSize: maxSize}
+	}
+	header := binary.LittleEndian.Uint32(buff) ^ ROUTEHANDLE_HEADER
+	if size := header & FRAME_LEN_MASK; size != uint32(len(buff)) {
+		return nil, fmt.Errorf("MsgLen Error: %d", size)
+	}
+	frame := &Frame{Flags: byte(header >> 24)}
+	if frame.Flags&^frameFlagsKnown != 0 {
+		return nil, fmt.Errorf("不支持的消息标志: 0x%02x", frame.Flags)
+	}
+	pos := FRAME_HEADER_SIZE
+	if frame.Flags&frameFlagsAlone != 0 {
+		if frame.Flags != FRAME_FLAG_HANDSHAKE && frame.Flags != FRAME_FLAG_ENCRYPT {
+			return nil, fmt.Errorf("不支持的消息标志: 0x%02x", frame.Flags)
+		}
+		frame.Body = buff[pos:]
+		return frame, nil
+	}
+	if frame.Flags&FRAME_FLAG_CMD != 0 {
+		if len(buff) < pos+4 {
+			return nil, ErrFrameTooSmall
+		}
+		frame.Cmd = binary.LittleEndian.Uint32(buff[pos:])
+		pos += 4
+	}
+	if frame.Flags&FRAME_FLAG_SEQ != 0 {
+		if len(buff) < pos+4 {
+			return nil, ErrFrameTooSmall
+		}
+		frame.Seq = binary.LittleEndian.Uint32(buff[pos:])
+		pos += 4
+	}
+	frame.Body = buff[pos:]
+	if frame.Flags&FRAME_FLAG_COMPRESS != 0 {
+		body, err := inflate(frame.Body, int(maxSize)-pos)
+		if err == errInflateTooLarge {
+			return nil, &ErrFrameTooLarge{Size: uint32(pos + len(body)), MaxSize: maxSize}
+		} else if err != nil {
+			return nil, fmt.Errorf("解压失败: %v", err)
+		}
+		frame.Body = body
+	}
+	return frame, nil
+}
+
+// Encode 编码成带消息头的数据, Flags带FRAME_FLAG_COMPRESS时压缩消息体
+func (e *Frame) Encode() ([]byte, error) {
+	if e.Flags&frameFlagsAlone != 0 && e.Flags != FRAME_FLAG_HANDSHAKE && e.Flags != FRAME_FLAG_ENCRYPT {
+		return nil, fmt.Errorf("不支持的消息标志: 0x%02x", e.Flags)
+	}
+	body := e.Body
+	if e.Flags&FRAME_FLAG_COMPRESS != 0 {
+		var err error
+		if body, err = deflate(body); err != nil {
+			return nil, err
+		}
+	}
+	size := FRAME_HEADER_SIZE + len(body)
+	if e.Flags&FRAME_FLAG_CMD != 0 {
+		size += 4
+	}
+	if e.Flags&FRAME_FLAG_SEQ != 0 {
+		size += 4
+	}
+	if uint32(size) > FRAME_MAX_SIZE {
+		return nil, &ErrFrameTooLarge{Size: uint32(size), MaxSize: FRAME_MAX_SIZE}
+	}
+	buff := make([]byte, size)
+	putFrameHeader(buff, uint32(size), e.Flags)
+	pos := FRAME_HEADER_SIZE
+	if e.Flags&FRAME_FLAG_CMD != 0 {
+		binary.LittleEndian.PutUint32(buff[pos:], e.Cmd)
+		pos += 4
+	}
+	if e.Flags&FRAME_FLAG_SEQ != 0 {
+		binary.LittleEndian.PutUint32(buff[pos:], e.Seq)
+		pos += 4
+	}
+	copy(buff[pos:], body)
+	return buff, nil
+}
+
+// FrameFormat 连接协商的消息格式
+type FrameFormat struct {
+	Codec     ICodec
+	HeaderCmd bool // cmd写在消息头中
+	Compress  int  // 消息体达到这个长度时压缩, 为0时不压缩
+}
+
+func putFrameHeader(buff []byte, size uint32, flags byte) {
+	binary.LittleEndian.PutUint32(buff, (size|uint32(flags)<<24)^ROUTEHANDLE_HEADER)
+}
@@ -0,0 +1,96 @@
+package Network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	FRAME_HEADER_SIZE = 4         // 消息头的长度
+	FRAME_READ_SIZE   = 1024 * 10 // 每次从连接读取的长度
+)
+
+var ErrFrameTooSmall = errors.New("消息长度小于消息头")
+
+// ErrFrameTooLarge 消息超过了允许的最大长度
+type ErrFrameTooLarge struct {
+	Size    uint32
+	MaxSize uint32
+}
+
+func (e *ErrFrameTooLarge) Error() string {
+	return fmt.Sprintf("消息长度%d超过了最大长度%d", e.Size, e.MaxSize)
+}
+
+// FrameDecoder 从字节流中拆分出完整的消息
+// 消息格式见Frame, 只根据消息头中的长度拆分, 不解析标志
+// 一次读取可以包含不完整的消息, 也可以包含多条消息
+type FrameDecoder struct {
+	maxSize uint32
+	buf     []byte // 已读取未处理的数据为 buf[start:]
+	start   int
+}
+
+func NewFrameDecoder(maxSize uint32) *FrameDecoder {
+	return &FrameDecoder{
+		maxSize: maxSize,
+		buf:     make([]byte, 0, FRAME_READ_SIZE),
+	}
+}
+
+// Feed 放入读取到的数据
+func (e *FrameDecoder) Feed(data []byte) {
+	e.compact(len(data))
+	e.buf = append(e.buf, data...)
+}
+
+// ReadOnce 从r读取一次数据
+func (e *FrameDecoder) ReadOnce(r io.Reader) (int, error) {
+	e.compact(FRAME_READ_SIZE)
+	end := len(e.buf)
+	n, err := r.Read(e.buf[end : end+FRAME_READ_SIZE])
+	e.buf = e.buf[:end+n]
+	return n, err
+}
+
+// Next 取出下一条完整的消息(包含消息头), 数据不够一条消息时返回nil
+// 返回的数据在下一次调用Feed或ReadOnce之前有效
+func (e *FrameDecoder) Next() ([]byte, error) {
+	data := e.buf[e.start:]
+	if len(data) < FRAME_HEADER_SIZE {
+		return nil, nil
+	}
+	size := (binary.LittleEndian.Uint32(data) ^ ROUTEHANDLE_HEADER) & FRAME_LEN_MASK
+	if size < FRAME_HEADER_SIZE {
+		return nil, ErrFrameTooSmall
+	}
+	if e.maxSize > 0 && size > e.maxSize {
+		return nil, &ErrFrameTooLarge{Size: size, MaxSize: e.maxSize}
+	}
+	if uint32(len(data)) < size {
+		return nil, nil
+	}
+	e.start += int(size)
+	return data[:size], nil
+}
+
+// Buffered 已读取未处理的字节数
+func (e *FrameDecoder) Buffered() int {
+	return len(e.buf) - e.start
+}
+
+// 把未处理的数据移到开头, 并保证还有n字节的空间
+func (e *FrameDecoder) compact(n int) {
+	if e.start > 0 {
+		remain := copy(e.buf, e.buf[e.start:])
+		e.buf = e.buf[:remain]
+		e.start = 0
+	}
+	if cap(e.buf)-len(e.buf) < n {
+		buf := make([]byte, len(e.buf), 2*cap(e.buf)+n)
+		copy(buf, e.buf)
+		e.buf = buf
+	}
+}
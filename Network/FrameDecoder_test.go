@@ -0,0 +1,123 @@
+package Network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func makeFrame(body string) []byte {
+	buff := make([]byte, FRAME_HEADER_SIZE+len(body))
+	binary.LittleEndian.PutUint32(buff, uint32(len(buff))^ROUTEHANDLE_HEADER)
+	copy(buff[FRAME_HEADER_SIZE:], body)
+	return buff
+}
+
+// 取出所有完整的消息体
+func drainFrames(t *testing.T, decoder *FrameDecoder) []string {
+	result := make([]string, 0)
+	for {
+		frame, err := decoder.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame == nil {
+			return result
+		}
+		result = append(result, string(frame[FRAME_HEADER_SIZE:]))
+	}
+}
+
+func TestFrameDecoderSplit(t *testing.T) {
+	stream := bytes.Join([][]byte{makeFrame(`{"cmd":1}`), makeFrame(""), makeFrame(`{"cmd":2}`)}, nil)
+	// 按各种长度切分后的结果都应该相同
+	for step := 1; step <= len(stream); step++ {
+		decoder := NewFrameDecoder(1024)
+		bodies := make([]string, 0)
+		for i := 0; i < len(stream); i += step {
+			end := i + step
+			if end > len(stream) {
+				end = len(stream)
+			}
+			decoder.Feed(stream[i:end])
+			bodies = append(bodies, drainFrames(t, decoder)...)
+		}
+		if len(bodies) != 3 || bodies[0] != `{"cmd":1}` || bodies[1] != "" || bodies[2] != `{"cmd":2}` {
+			t.Fatalf("step=%d 拆分结果不正确: %q", step, bodies)
+		}
+		if decoder.Buffered() != 0 {
+			t.Fatalf("step=%d 剩余%d字节", step, decoder.Buffered())
+		}
+	}
+}
+
+func TestFrameDecoderReadOnce(t *testing.T) {
+	body := string(bytes.Repeat([]byte("x"), FRAME_READ_SIZE*3))
+	stream := bytes.NewReader(append(makeFrame(body), makeFrame("y")...))
+	decoder := NewFrameDecoder(0)
+	bodies := make([]string, 0)
+	for stream.Len() > 0 {
+		if _, err := decoder.ReadOnce(stream); err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, drainFrames(t, decoder)...)
+	}
+	if len(bodies) != 2 || bodies[0] != body || bodies[1] != "y" {
+		t.Fatalf("读取结果不正确: %d条", len(bodies))
+	}
+}
+
+func TestFrameDecoderErrors(t *testing.T) {
+	decoder := NewFrameDecoder(16)
+	decoder.Feed(makeFrame("0123456789abcdef"))
+	if _, err := decoder.Next(); err == nil {
+		t.Fatal("超过最大长度时应该返回错误")
+	} else if e, ok := err.(*ErrFrameTooLarge); !ok || e.Size != 20 || e.MaxSize != 16 {
+		t.Fatalf("错误不正确: %v", err)
+	}
+
+	decoder = NewFrameDecoder(16)
+	header := make([]byte, FRAME_HEADER_SIZE)
+	binary.LittleEndian.PutUint32(header, 2^ROUTEHANDLE_HEADER)
+	decoder.Feed(header)
+	if _, err := decoder.Next(); err != ErrFrameTooSmall {
+		t.Fatalf("长度小于消息头时应该返回ErrFrameTooSmall, 实际为%v", err)
+	}
+
+	// 消息头不完整时不是错误
+	decoder = NewFrameDecoder(16)
+	decoder.Feed(makeFrame("ab")[:3])
+	if frame, err := decoder.Next(); frame != nil || err != nil {
+		t.Fatalf("消息头不完整时应该等待更多数据: %v %v", frame, err)
+	}
+}
+
+func TestFrameCompress(t *testing.T) {
+	body := bytes.Repeat([]byte(`{"id":1,"num":100},`), 1000)
+	frame := &Frame{Flags: FRAME_FLAG_CMD | FRAME_FLAG_COMPRESS, Cmd: 7, Body: body}
+	buff, err := frame.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buff) >= len(body) {
+		t.Fatalf("压缩后的长度没有变小: %d", len(buff))
+	}
+	result, err := ParseFrame(buff, 64*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Cmd != 7 || !bytes.Equal(result.Body, body) {
+		t.Fatal("解压结果不正确")
+	}
+
+	// 压缩后很小, 解压后超过最大长度
+	bomb, err := (&Frame{Flags: FRAME_FLAG_COMPRESS, Body: make([]byte, 1024*1024)}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseFrame(bomb, 64*1024); err == nil {
+		t.Fatal("解压后超过最大长度时应该返回错误")
+	} else if e, ok := err.(*ErrFrameTooLarge); !ok || e.MaxSize != 64*1024 {
+		t.Fatalf("错误不正确: %v", err)
+	}
+}
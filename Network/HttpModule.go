@@ -2,32 +2,41 @@ package Network
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"github.com/team-zf/framework/config"
 	"github.com/team-zf/framework/logger"
 	"github.com/team-zf/framework/messages"
 	"github.com/team-zf/framework/modules"
 	"github.com/team-zf/framework/utils"
 	"github.com/team-zf/framework/utils/threads"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strconv"
 	"sync/atomic"
 	"time"
 )
 
 type HttpModule struct {
 	name         string
+	depends      []string
 	ipPort       string
 	httpServer   *http.Server
+	fault        chan error
 	routeHandle  *HttpRouteHandle
+	handlers     map[string]http.Handler // 额外挂载的http接口
 	thgo         *threads.ThreadGo
 	timeout      time.Duration
 	timeoutFun   func(IHttpRoute, http.ResponseWriter, *http.Request)
-	requestCount int64 // 收到的请求总数
-	runingCount  int64 // 正在运行的总数
+	codec        ICodec       // 默认的编码方式, 为nil时使用routeHandle的默认编码
+	limiter      *RateLimiter // 请求频率限制, 为nil时不限制
+	middlewares  *Middlewares // 包装路由执行的中间件
+	maintenance  int32        // 是否处于维护模式
+	requestCount int64        // 收到的请求总数
+	runingCount  int64        // 正在运行的总数
 }
 
-func (e *HttpModule) Init() {
+func (e *HttpModule) Init() error {
+	e.fault = make(chan error, 1)
 	e.httpServer = &http.Server{
 		Addr:         e.ipPort,
 		WriteTimeout: e.timeout,
@@ -36,35 +45,72 @@ func (e *HttpModule) Init() {
 	mux := http.NewServeMux()
 	// 这个是主要的逻辑
 	mux.HandleFunc("/", e.Handle)
+	for pattern, handler := range e.handlers {
+		mux.Handle(pattern, handler)
+	}
 	e.httpServer.Handler = mux
+	return nil
 }
 
-func (e *HttpModule) Start() {
+func (e *HttpModule) Start() error {
+	// 先同步监听端口, 这样端口被占用等错误可以直接返回
+	ln, err := net.Listen("tcp", e.ipPort)
+	if err != nil {
+		return err
+	}
 	e.thgo.Go(func(ctx context.Context) {
 		logger.Notice("%s启动", e.name)
-		err := e.httpServer.ListenAndServe()
-		if err != nil {
-			if err != http.ErrServerClosed {
-				logger.Error("Server closed unexpecteed; %v", err)
-			}
+		err := e.httpServer.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {
+			logger.Error("%s异常关闭, 原因: %+v", e.name, err)
+			e.fault <- err
 		}
 	})
+	return nil
 }
 
-func (e *HttpModule) Stop() {
-	if err := e.httpServer.Close(); err != nil {
-		logger.Error("Close Http Module; %v", err)
-	}
+// Drain 停止接受新请求, 并等待正在处理的请求完成
+func (e *HttpModule) Drain(ctx context.Context) error {
+	return e.httpServer.Shutdown(ctx)
+}
+
+func (e *HttpModule) Stop() error {
+	err := e.httpServer.Close()
 	e.thgo.CloseWait()
 	logger.Notice("%s已停止", e.name)
+	return err
+}
+
+func (e *HttpModule) Fault() <-chan error {
+	return e.fault
+}
+
+func (e *HttpModule) Name() string {
+	return e.name
 }
 
-func (e *HttpModule) PrintStatus() string {
-	return fmt.Sprintf(
-		"\r\n\t\t%s的状态:\t%d/%d\t(Runing/Request)",
-		e.name,
-		atomic.LoadInt64(&e.runingCount),
-		atomic.LoadInt64(&e.requestCount))
+func (e *HttpModule) DependsOn() []string {
+	return e.depends
+}
+
+func (e *HttpModule) SetMaintenance(v bool) {
+	if v {
+		atomic.StoreInt32(&e.maintenance, 1)
+	} else {
+		atomic.StoreInt32(&e.maintenance, 0)
+	}
+}
+
+// Middlewares 中间件, 可以在启动前继续注册
+func (e *HttpModule) Middlewares() *Middlewares {
+	return e.middlewares
+}
+
+func (e *HttpModule) Status() *modules.ModuleStatus {
+	status := modules.NewModuleStatus(e.name).
+		Gauge("runingCount", float64(atomic.LoadInt64(&e.runingCount))).
+		Counter("requestCount", atomic.LoadInt64(&e.requestCount))
+	return e.limiter.addStatus(status)
 }
 
 func (e *HttpModule) Handle(res http.ResponseWriter, req *http.Request) {
@@ -73,8 +119,30 @@ func (e *HttpModule) Handle(res http.ResponseWriter, req *http.Request) {
 	e.thgo.Wg.Add(1)
 	defer e.thgo.Wg.Done()
 
+	codec := e.requestCodec(req)
+	if codec == nil {
+		logger.Error("%s不支持的编码方式: %s", e.name, req.URL.Query().Get("codec"))
+		res.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	// 被封禁的IP不读取消息
+	ip := e.limiter.ClientIP(req)
+	if e.limiter != nil && e.limiter.Banned(ip) {
+		e.rateLimited(res, req)
+		return
+	}
+	// cmd可以放在请求头中, 不需要从消息体中查找
+	var cmd uint32
+	if header := req.Header.Get(HTTP_CMD_HEADER); header != "" {
+		v, err := strconv.ParseUint(header, 10, 32)
+		if err != nil {
+			logger.Error("%s请求头%s有误: %s", e.name, HTTP_CMD_HEADER, header)
+			return
+		}
+		cmd = uint32(v)
+	}
 	buff, _ := ioutil.ReadAll(req.Body)
-	msg, err := e.routeHandle.Unmarshal(buff)
+	msg, err := e.routeHandle.UnmarshalWith(codec, cmd, buff)
 	if err != nil {
 		logger.Error("%s消息解码失败, 原因: %+v", e.name, err)
 		return
@@ -82,13 +150,69 @@ func (e *HttpModule) Handle(res http.ResponseWriter, req *http.Request) {
 	route, _ := msg.(IHttpRoute)
 	logger.Notice("%s收到请求: %s", e.name, route.Header())
 
+	if e.limiter != nil {
+		if ok, punish := e.limiter.Allow(ip, "", route); !ok {
+			if punish {
+				logger.Warn("%s请求过于频繁: %s", e.name, ip)
+			}
+			observeLimited(e.name, route.GetCmd())
+			e.rateLimited(res, req)
+			return
+		}
+	}
+
 	atomic.AddInt64(&e.requestCount, 1)
 	atomic.AddInt64(&e.runingCount, 1)
+	observeRequest(e.name, route.GetCmd())
 	e.TryDirectCall(route, res, req)
 	atomic.AddInt64(&e.runingCount, -1)
 }
 
 func (e *HttpModule) TryDirectCall(route IHttpRoute, res http.ResponseWriter, req *http.Request) {
+	ctx := newRouteContext(route)
+	ctx.Request = req
+	ctx.Writer = res
+	ctx.Code = messages.RC_Success
+	defer func() {
+		observeResponse(e.name, route.GetCmd(), ctx.Code, ctx.Begin)
+	}()
+
+	// 维护中, 不处理请求
+	if atomic.LoadInt32(&e.maintenance) == 1 {
+		ctx.Code = messages.RC_Maintenance
+		if buff, err := e.marshal(req, &HttpResponse{Code: ctx.Code}); err == nil {
+			res.Write(buff)
+		}
+		return
+	}
+
+	threads.Try(func() {
+		e.middlewares.run(ctx, func() {
+			e.callRoute(ctx, route, res, req)
+		})
+	}, func(err error) {
+		logger.Error("%s中间件错误: %s, Error: %+v", e.name, route.Header(), err)
+		ctx.Code = messages.RC_LOGIC_ERROR
+		ctx.Result = nil
+	})
+
+	// 超时的情况下已经回应过
+	if ctx.replied {
+		return
+	}
+	var resp interface{} = &HttpResponse{Code: ctx.Code}
+	if ctx.Result != nil {
+		// 中间件可能修改了结果代码
+		ctx.Result["code"] = ctx.Code
+		resp = ctx.Result
+	}
+	if buff, err := e.marshal(req, resp); err == nil {
+		res.Write(buff)
+	}
+}
+
+// 参数解析和逻辑运行, 结果写入ctx
+func (e *HttpModule) callRoute(ctx *RouteContext, route IHttpRoute, res http.ResponseWriter, req *http.Request) {
 	utils.QueueRun(
 		func() bool {
 			result := true
@@ -96,81 +220,79 @@ func (e *HttpModule) TryDirectCall(route IHttpRoute, res http.ResponseWriter, re
 				route.Parse()
 			}, func(err error) {
 				result = false
-				resp := &HttpResponse{
-					Code: messages.RC_Param_Error,
-				}
-				if buff, err := e.routeHandle.Marshal(resp); err == nil {
-					res.Write(buff)
-				}
+				ctx.Code = messages.RC_Param_Error
 			})
 			return result
 		},
 		func() bool {
-			result := true
-			threads.Try(
-				func() {
-					t := time.NewTimer(e.timeout - 2*time.Second)
-					g := threads.NewGoRun(func() {
-						threads.Try(
-							func() {
-								code := route.Handle(req)
-								resp := &HttpResponse{
-									Code: code,
-								}
-								buff, _ := json.Marshal(resp)
-								jsmap := make(map[string]interface{})
-								json.Unmarshal(buff, &jsmap)
-								for k, v := range route.ToJsonMap() {
-									if _, ok := jsmap[k]; !ok {
-										jsmap[k] = v
-									}
-								}
-								if buff, err := e.routeHandle.Marshal(jsmap); err == nil {
-									res.Write(buff)
-								}
-							},
-							func(err error) {
-								logger.Error("%s; 逻辑报错: %+v", route.Header(), err)
-								resp := &HttpResponse{
-									Code: messages.RC_LOGIC_ERROR,
-								}
-								// 返回逻辑错误
-								if buff, err := e.routeHandle.Marshal(resp); err == nil {
-									res.Write(buff)
-								}
-							},
-						)
-					})
-					select {
-					// 业务逻辑完成
-					case <-g.Chanresult:
-						t.Stop()
-						break
-					// 业务逻辑超时
-					case <-t.C:
-						if e.timeoutFun != nil {
-							e.timeoutFun(route, res, req)
-						} else {
-							e.defaultTimeoutFunc(route, res, req)
-						}
-						break
-					}
-				},
-				func(err error) {
-					result = false
-					resp := &HttpResponse{
-						Code: messages.RC_Param_Error,
-					}
-					if buff, err := e.routeHandle.Marshal(resp); err == nil {
-						res.Write(buff)
-					}
-				},
-			)
-			return result
+			t := time.NewTimer(e.timeout - 2*time.Second)
+			// 只在逻辑完成后读取, 超时的情况下不读取
+			var handleCode uint32
+			var handleResult map[string]interface{}
+			g := threads.NewGoRun(func() {
+				threads.Try(
+					func() {
+						handleCode = route.Handle(req)
+						handleResult = routeResult(&HttpResponse{
+							Code: handleCode,
+						}, route)
+					},
+					func(err error) {
+						logger.Error("%s; 逻辑报错: %+v", route.Header(), err)
+						handleCode = messages.RC_LOGIC_ERROR
+						handleResult = nil
+					},
+				)
+			})
+			select {
+			// 业务逻辑完成
+			case <-g.Chanresult:
+				t.Stop()
+				ctx.Code = handleCode
+				ctx.Result = handleResult
+			// 业务逻辑超时
+			case <-t.C:
+				ctx.Code = messages.RC_Timeout
+				ctx.replied = true
+				if e.timeoutFun != nil {
+					e.timeoutFun(route, res, req)
+				} else {
+					e.defaultTimeoutFunc(route, res, req)
+				}
+			}
+			return true
 		},
 	)
 }
 
+// 请求使用的编码方式, 可以用 ?codec=名称 选择, 不支持时返回nil
+func (e *HttpModule) requestCodec(req *http.Request) ICodec {
+	if name := req.URL.Query().Get("codec"); name != "" {
+		return GetCodec(name)
+	}
+	if e.codec != nil {
+		return e.codec
+	}
+	return e.routeHandle.Codec()
+}
+
+// 按请求的编码方式编码
+func (e *HttpModule) marshal(req *http.Request, data interface{}) ([]byte, error) {
+	codec := e.requestCodec(req)
+	if codec == nil {
+		codec = e.routeHandle.Codec()
+	}
+	return codec.Marshal(data)
+}
+
+// 返回429和RC_RateLimited
+func (e *HttpModule) rateLimited(res http.ResponseWriter, req *http.Request) {
+	res.WriteHeader(http.StatusTooManyRequests)
+	if buff, err := e.marshal(req, &HttpResponse{Code: messages.RC_RateLimited}); err == nil {
+		res.Write(buff)
+	}
+}
+
 func (e *HttpModule) defaultTimeoutFunc(route IHttpRoute, res http.ResponseWriter, req *http.Request) {
 	res.Write([]byte("timeout Run!"))
 }
@@ -182,6 +304,8 @@ func NewHttpModule(opts ...modules.ModOptions) *HttpModule {
 		timeout:     30 * time.Second,
 		thgo:        threads.NewThreadGo(),
 		routeHandle: NewHttpRouteHandle(),
+		handlers:    make(map[string]http.Handler),
+		middlewares: NewMiddlewares(),
 	}
 	for _, opt := range opts {
 		opt(result)
@@ -196,6 +320,13 @@ func HttpSetName(v string) modules.ModOptions {
 	}
 }
 
+// 设置依赖的模块
+func HttpSetDependsOn(v ...string) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*HttpModule).depends = v
+	}
+}
+
 // 设置Web地址
 func HttpSetIpPort(ipPort string) modules.ModOptions {
 	return func(mod modules.IModule) {
@@ -217,9 +348,72 @@ func HttpSetTimeoutFunc(timeoutfunc func(IHttpRoute, http.ResponseWriter, *http.
 	}
 }
 
+// 挂载额外的http接口, 如 HttpSetHandler("/healthz", app.HealthzHandler())
+func HttpSetHandler(pattern string, handler http.Handler) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*HttpModule).handlers[pattern] = handler
+	}
+}
+
 // 设置路由
 func HttpSetRoute(route *HttpRouteHandle) modules.ModOptions {
 	return func(mod modules.IModule) {
 		mod.(*HttpModule).routeHandle = route
 	}
 }
+
+// 按配置文件中的Http部分设置监听地址和超时
+func HttpSetConfig(v *config.HttpConfig) modules.ModOptions {
+	return func(mod modules.IModule) {
+		if v == nil {
+			return
+		}
+		e := mod.(*HttpModule)
+		e.ipPort = v.Addr
+		if v.Timeout > 0 {
+			e.timeout = time.Duration(v.Timeout) * time.Second
+		}
+		if v.Codec != "" {
+			if codec := GetCodec(v.Codec); codec != nil {
+				e.codec = codec
+			} else {
+				logger.Error("%s不支持的编码方式: %s, 使用默认的编码方式", e.name, v.Codec)
+			}
+		}
+		if v.RateLimit != nil {
+			e.limiter = NewRateLimiter(v.RateLimit)
+		}
+	}
+}
+
+// 设置中间件, 可以和WebSocket模块共用同一个
+func HttpSetMiddlewares(v *Middlewares) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*HttpModule).middlewares = v
+	}
+}
+
+// 注册对所有cmd生效的中间件
+func HttpUse(fns ...Middleware) modules.ModOptions {
+	return func(mod modules.IModule) {
+		e := mod.(*HttpModule)
+		if e.middlewares == nil {
+			e.middlewares = NewMiddlewares()
+		}
+		e.middlewares.Use(fns...)
+	}
+}
+
+// 设置请求频率限制, 为nil时不限制, 按IP和cmd限制
+func HttpSetRateLimiter(v *RateLimiter) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*HttpModule).limiter = v
+	}
+}
+
+// 设置默认的编码方式, 客户端可以在请求时用 ?codec=名称 另外选择
+func HttpSetCodec(v ICodec) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*HttpModule).codec = v
+	}
+}
@@ -2,6 +2,9 @@ package Network
 
 import "fmt"
 
+// HTTP_CMD_HEADER 请求头中的cmd, 有这个请求头时不需要从消息体中查找cmd
+const HTTP_CMD_HEADER = "X-Cmd"
+
 type HttpRoute struct {
 	Cmd    uint32                 `json:"cmd"`
 	Params map[string]interface{} `json:"params"`
@@ -12,6 +15,10 @@ func (e *HttpRoute) GetCmd() uint32 {
 	return e.Cmd
 }
 
+func (e *HttpRoute) SetCmd(cmd uint32) {
+	e.Cmd = cmd
+}
+
 func (e *HttpRoute) Header() string {
 	return fmt.Sprintf("Cmd: %d, Params: %+v", e.Cmd, e.Params)
 }
@@ -1,37 +1,54 @@
 package Network
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/team-zf/framework/utils"
 )
 
 type HttpRouteHandle struct {
 	routes map[uint32]interface{}
+	codec  ICodec // 默认的编码方式
 }
 
 func (e *HttpRouteHandle) Marshal(data interface{}) ([]byte, error) {
-	return json.Marshal(data)
+	return e.codec.Marshal(data)
 }
 
 func (e *HttpRouteHandle) Unmarshal(buff []byte) (data interface{}, err error) {
-	jsmap := make(map[string]interface{})
-	if err = json.Unmarshal(buff, &jsmap); err != nil {
-		return
-	}
-	var cmd uint32
-	cmd, err = utils.NewStringAny(jsmap["cmd"]).ToUint32()
-	if err != nil {
-		return
+	return e.UnmarshalWith(e.codec, 0, buff)
+}
+
+// UnmarshalWith 用指定的编码方式解码, cmd为0时从消息体中查找cmd
+func (e *HttpRouteHandle) UnmarshalWith(codec ICodec, cmd uint32, buff []byte) (data interface{}, err error) {
+	if cmd == 0 {
+		if cmd, _, err = decodeCmd(codec, buff); err != nil {
+			return
+		}
 	}
 	data, err = e.GetRoute(cmd)
 	if err != nil {
 		return
 	}
-	err = json.Unmarshal(buff, data)
+	if len(buff) > 0 {
+		if err = codec.Unmarshal(buff, data); err != nil {
+			return
+		}
+	}
+	if setter, ok := data.(ICmdSetter); ok {
+		setter.SetCmd(cmd)
+	}
 	return
 }
 
+func (e *HttpRouteHandle) Codec() ICodec {
+	return e.codec
+}
+
+// SetCodec 设置默认的编码方式
+func (e *HttpRouteHandle) SetCodec(codec ICodec) {
+	e.codec = codec
+}
+
 func (e *HttpRouteHandle) CheckMaxLenVaild(buff []byte) (msglen uint32, ok bool) {
 	return uint32(len(buff)), true
 }
@@ -52,5 +69,6 @@ func (e *HttpRouteHandle) GetRoute(cmd uint32) (msg interface{}, err error) {
 func NewHttpRouteHandle() *HttpRouteHandle {
 	return &HttpRouteHandle{
 		routes: make(map[uint32]interface{}),
+		codec:  JsonCodec,
 	}
 }
@@ -26,3 +26,8 @@ type IWebSocketRoute interface {
 	// 输出JsonMap
 	ToJsonMap() map[string]interface{}
 }
+
+// ICmdSetter 消息头中带cmd时, 用于把cmd写回路由
+type ICmdSetter interface {
+	SetCmd(cmd uint32)
+}
@@ -0,0 +1,35 @@
+package Network
+
+import (
+	"github.com/team-zf/framework/metrics"
+	"strconv"
+	"time"
+)
+
+var (
+	metricRequests    = metrics.NewCounter("zf_requests_total", "收到的请求总数", "module", "cmd")
+	metricResponses   = metrics.NewCounter("zf_responses_total", "按结果代码统计的响应总数", "module", "cmd", "code")
+	metricDuration    = metrics.NewHistogram("zf_request_duration_seconds", "请求的处理耗时(秒)", nil, "module", "cmd")
+	metricOnline      = metrics.NewGauge("zf_websocket_online", "WebSocket当前连接数", "module")
+	metricConnects    = metrics.NewCounter("zf_websocket_connections_total", "WebSocket连接总数", "module")
+	metricDisconnects = metrics.NewCounter("zf_websocket_disconnects_total", "按原因统计的WebSocket断开总数", "module", "reason")
+	metricDropped     = metrics.NewCounter("zf_websocket_dropped_total", "因发送队列满丢弃的消息总数", "module")
+	metricLimited     = metrics.NewCounter("zf_rate_limited_total", "被限流的请求总数", "module", "cmd")
+)
+
+// 记录收到的请求
+func observeRequest(module string, cmd uint32) {
+	metricRequests.Inc(module, strconv.FormatUint(uint64(cmd), 10))
+}
+
+// 记录被限流的请求, 只计数, 不计入响应和耗时的统计, 以免拉低请求的耗时
+func observeLimited(module string, cmd uint32) {
+	metricLimited.Inc(module, strconv.FormatUint(uint64(cmd), 10))
+}
+
+// 记录请求的结果代码和处理耗时
+func observeResponse(module string, cmd uint32, code uint32, begin time.Time) {
+	scmd := strconv.FormatUint(uint64(cmd), 10)
+	metricResponses.Inc(module, scmd, strconv.FormatUint(uint64(code), 10))
+	metricDuration.Observe(time.Since(begin).Seconds(), module, scmd)
+}
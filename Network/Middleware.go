@@ -0,0 +1,137 @@
+package Network
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RouteContext 中间件可以访问的请求信息, Http和WebSocket共用
+type RouteContext struct {
+	Route   IRoute
+	Agent   *WebSocketAgent     // WebSocket的连接, Http请求时为nil
+	Request *http.Request       // Http的请求, WebSocket时为建立连接的请求
+	Writer  http.ResponseWriter // Http请求时有效
+	Begin   time.Time           // 开始处理的时间
+	Code    uint32              // 结果代码, 中间件不调用next时作为回应返回
+	// 路由处理成功后回应的内容, 中间件可以在next返回后修改
+	// 为nil时只回应cmd和Code
+	Result  map[string]interface{}
+	values  map[string]interface{}
+	replied bool // 已经直接回应过, 如Http超时
+}
+
+func newRouteContext(route IRoute) *RouteContext {
+	return &RouteContext{
+		Route: route,
+		Begin: time.Now(),
+	}
+}
+
+func (e *RouteContext) Cmd() uint32 {
+	return e.Route.GetCmd()
+}
+
+// IsWebSocket 是否是WebSocket的请求
+func (e *RouteContext) IsWebSocket() bool {
+	return e.Agent != nil
+}
+
+// Set 保存数据, 供后面的中间件和路由使用
+func (e *RouteContext) Set(key string, value interface{}) {
+	if e.values == nil {
+		e.values = make(map[string]interface{})
+	}
+	e.values[key] = value
+}
+
+func (e *RouteContext) Get(key string) (interface{}, bool) {
+	value, ok := e.values[key]
+	return value, ok
+}
+
+// Middleware 包装路由的执行, 调用next继续执行后面的中间件和路由
+// 不调用next时不再执行路由, 用ctx.Code作为结果代码回应
+type Middleware func(ctx *RouteContext, next func())
+
+type middlewareEntry struct {
+	min, max uint32 // cmd的范围, 包含两端
+	fn       Middleware
+}
+
+// Middlewares 中间件列表, 按注册的顺序执行, 可以同时给Http和WebSocket模块使用
+type Middlewares struct {
+	lock    sync.RWMutex
+	entries []middlewareEntry
+}
+
+func NewMiddlewares() *Middlewares {
+	return &Middlewares{}
+}
+
+// Use 注册对所有cmd生效的中间件
+func (e *Middlewares) Use(fns ...Middleware) *Middlewares {
+	for _, fn := range fns {
+		e.UseRange(0, ^uint32(0), fn)
+	}
+	return e
+}
+
+// UseRange 注册只对min~max之间的cmd生效的中间件
+func (e *Middlewares) UseRange(min, max uint32, fn Middleware) *Middlewares {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	// 复制一份, 正在执行的请求不受影响
+	entries := make([]middlewareEntry, len(e.entries), len(e.entries)+1)
+	copy(entries, e.entries)
+	e.entries = append(entries, middlewareEntry{min: min, max: max, fn: fn})
+	return e
+}
+
+// 按顺序执行对这个cmd生效的中间件, 最后执行handle
+func (e *Middlewares) run(ctx *RouteContext, handle func()) {
+	if e == nil {
+		handle()
+		return
+	}
+	e.lock.RLock()
+	entries := e.entries
+	e.lock.RUnlock()
+
+	cmd := ctx.Cmd()
+	var step func(i int)
+	step = func(i int) {
+		for ; i < len(entries); i++ {
+			entry := entries[i]
+			if cmd >= entry.min && cmd <= entry.max {
+				// 每个中间件的next只生效一次, 多次调用不会重复执行后面的中间件和路由
+				called := false
+				entry.fn(ctx, func() {
+					if !called {
+						called = true
+						step(i + 1)
+					}
+				})
+				return
+			}
+		}
+		handle()
+	}
+	step(0)
+}
+
+// 合并结果代码和路由的输出, 作为回应的内容
+func routeResult(resp interface{}, route interface {
+	ToJsonMap() map[string]interface{}
+}) map[string]interface{} {
+	jsmap := make(map[string]interface{})
+	buff, _ := json.Marshal(resp)
+	json.Unmarshal(buff, &jsmap)
+	for k, v := range route.ToJsonMap() {
+		if _, ok := jsmap[k]; !ok {
+			jsmap[k] = v
+		}
+	}
+	return jsmap
+}
@@ -0,0 +1,98 @@
+package Network
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/team-zf/framework/messages"
+)
+
+type middlewareTestRoute struct {
+	HttpRoute
+}
+
+func (e *middlewareTestRoute) Parse() {}
+
+func (e *middlewareTestRoute) Handle(req *http.Request) uint32 {
+	e.Data("handled", true)
+	return messages.RC_Success
+}
+
+func TestMiddlewares(t *testing.T) {
+	handle := NewHttpRouteHandle()
+	handle.SetRoute(7, &middlewareTestRoute{})
+	handle.SetRoute(1001, &middlewareTestRoute{})
+
+	order := make([]string, 0)
+	chain := NewMiddlewares().
+		Use(func(ctx *RouteContext, next func()) {
+			order = append(order, "a")
+			next()
+			// 修改路由的回应
+			if ctx.Result != nil {
+				ctx.Result["extra"] = ctx.Cmd()
+			}
+		}).
+		UseRange(1000, 1999, func(ctx *RouteContext, next func()) {
+			order = append(order, "b")
+			// 没有登录时不执行路由
+			if ctx.Request.Header.Get("Token") == "" {
+				ctx.Code = messages.RC_NoPermission
+				return
+			}
+			next()
+		})
+	mod := NewHttpModule(HttpSetRoute(handle), HttpSetMiddlewares(chain))
+
+	call := func(cmd uint32, token string) map[string]interface{} {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader([]byte(`{}`)))
+		req.Header.Set(HTTP_CMD_HEADER, strconv.FormatUint(uint64(cmd), 10))
+		if token != "" {
+			req.Header.Set("Token", token)
+		}
+		res := httptest.NewRecorder()
+		mod.Handle(res, req)
+		result := make(map[string]interface{})
+		if err := json.Unmarshal(res.Body.Bytes(), &result); err != nil {
+			t.Fatal(err, res.Body.String())
+		}
+		return result
+	}
+
+	// 范围外的cmd只经过全局的中间件
+	result := call(7, "")
+	if result["code"] != float64(messages.RC_Success) || result["extra"] != float64(7) || len(order) != 1 {
+		t.Fatalf("回应不正确: %v %v", result, order)
+	}
+	// 中间件直接返回结果代码
+	order = order[:0]
+	result = call(1001, "")
+	if result["code"] != float64(messages.RC_NoPermission) || result["data"] != nil || len(order) != 2 {
+		t.Fatalf("回应不正确: %v %v", result, order)
+	}
+	result = call(1001, "x")
+	if result["code"] != float64(messages.RC_Success) || result["extra"] != float64(1001) {
+		t.Fatalf("回应不正确: %v", result)
+	}
+}
+
+// 中间件多次调用next时路由只执行一次
+func TestMiddlewaresNextOnce(t *testing.T) {
+	count := 0
+	chain := NewMiddlewares().
+		Use(func(ctx *RouteContext, next func()) {
+			next()
+			next()
+		}).
+		Use(func(ctx *RouteContext, next func()) {
+			next()
+		})
+	chain.run(newRouteContext(&WebSocketRoute{Cmd: 7}), func() { count++ })
+	if count != 1 {
+		t.Fatalf("路由执行了%d次", count)
+	}
+}
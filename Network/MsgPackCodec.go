@@ -0,0 +1,348 @@
+package Network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// msgPackCodec MessagePack格式的编码, 字段名和规则与JSON相同, 比JSON更紧凑
+// 支持 nil, bool, 整数, 浮点数, 字符串, 二进制, 数组, 键为字符串的对象
+// 整数解码为int64, 超出int64范围的无符号整数返回错误
+type msgPackCodec struct{}
+
+var errMsgPackShort = errors.New("msgpack: 数据不完整")
+
+func (e *msgPackCodec) Name() string {
+	return "msgpack"
+}
+
+func (e *msgPackCodec) Marshal(v interface{}) ([]byte, error) {
+	value, err := toPlain(v)
+	if err != nil {
+		return nil, err
+	}
+	buff := &bytes.Buffer{}
+	if err := msgPackEncode(buff, value); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+func (e *msgPackCodec) Unmarshal(data []byte, v interface{}) error {
+	value, n, err := msgPackDecode(data)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("msgpack: 数据末尾有%d字节多余", len(data)-n)
+	}
+	// 解码到基本类型时直接赋值
+	switch p := v.(type) {
+	case *interface{}:
+		*p = value
+		return nil
+	case *map[string]interface{}:
+		if m, ok := value.(map[string]interface{}); ok {
+			*p = m
+			return nil
+		}
+	case *[]interface{}:
+		if a, ok := value.([]interface{}); ok {
+			*p = a
+			return nil
+		}
+	}
+	// 其他类型按JSON的规则填充到v中, 需要多一次JSON编码和解码, 比直接解码JSON慢
+	// 对性能敏感的消息可以解码到map[string]interface{}后自己取值
+	buff, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buff, v)
+}
+
+// 把任意对象按JSON的规则转换成基本类型
+func toPlain(v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case nil, bool, string, []byte, float64, int, int64, uint32, map[string]interface{}, []interface{}:
+		return v, nil
+	}
+	buff, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(buff))
+	decoder.UseNumber()
+	var result interface{}
+	err = decoder.Decode(&result)
+	return result, err
+}
+
+func msgPackEncode(buff *bytes.Buffer, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		buff.WriteByte(0xc0)
+	case bool:
+		if value {
+			buff.WriteByte(0xc3)
+		} else {
+			buff.WriteByte(0xc2)
+		}
+	case int:
+		msgPackInt(buff, int64(value))
+	case int64:
+		msgPackInt(buff, value)
+	case uint32:
+		msgPackInt(buff, int64(value))
+	case float64:
+		if value == math.Trunc(value) && math.Abs(value) < 1<<53 {
+			msgPackInt(buff, int64(value))
+		} else {
+			buff.WriteByte(0xcb)
+			binary.Write(buff, binary.BigEndian, value)
+		}
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			msgPackInt(buff, i)
+		} else if f, err := value.Float64(); err == nil {
+			return msgPackEncode(buff, f)
+		} else {
+			return err
+		}
+	case string:
+		msgPackLen(buff, len(value), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		buff.WriteString(value)
+	case []byte:
+		msgPackLen(buff, len(value), 0, 0, 0xc4, 0xc5, 0xc6)
+		buff.Write(value)
+	case []interface{}:
+		msgPackLen(buff, len(value), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, item := range value {
+			if err := msgPackEncode(buff, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		msgPackLen(buff, len(value), 0x80, 16, 0, 0xde, 0xdf)
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			msgPackEncode(buff, k)
+			if err := msgPackEncode(buff, value[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		plain, err := toPlain(v)
+		if err != nil {
+			return err
+		}
+		return msgPackEncode(buff, plain)
+	}
+	return nil
+}
+
+func msgPackInt(buff *bytes.Buffer, v int64) {
+	switch {
+	case v >= 0 && v <= 0x7f:
+		buff.WriteByte(byte(v))
+	case v >= -32 && v < 0:
+		buff.WriteByte(byte(v))
+	case v >= 0 && v <= math.MaxUint8:
+		buff.Write([]byte{0xcc, byte(v)})
+	case v >= 0 && v <= math.MaxUint16:
+		buff.WriteByte(0xcd)
+		binary.Write(buff, binary.BigEndian, uint16(v))
+	case v >= 0 && v <= math.MaxUint32:
+		buff.WriteByte(0xce)
+		binary.Write(buff, binary.BigEndian, uint32(v))
+	case v >= math.MinInt8 && v < 0:
+		buff.Write([]byte{0xd0, byte(v)})
+	case v >= math.MinInt16 && v < 0:
+		buff.WriteByte(0xd1)
+		binary.Write(buff, binary.BigEndian, int16(v))
+	case v >= math.MinInt32 && v < 0:
+		buff.WriteByte(0xd2)
+		binary.Write(buff, binary.BigEndian, int32(v))
+	default:
+		buff.WriteByte(0xd3)
+		binary.Write(buff, binary.BigEndian, v)
+	}
+}
+
+// 写入长度, fix为0时没有fix格式, c8为0时没有8位长度的格式
+func msgPackLen(buff *bytes.Buffer, n int, fix byte, fixMax int, c8, c16, c32 byte) {
+	switch {
+	case fix != 0 && n < fixMax:
+		buff.WriteByte(fix | byte(n))
+	case c8 != 0 && n <= math.MaxUint8:
+		buff.Write([]byte{c8, byte(n)})
+	case n <= math.MaxUint16:
+		buff.WriteByte(c16)
+		binary.Write(buff, binary.BigEndian, uint16(n))
+	default:
+		buff.WriteByte(c32)
+		binary.Write(buff, binary.BigEndian, uint32(n))
+	}
+}
+
+// 解码一个值, 返回值和使用的字节数
+func msgPackDecode(data []byte) (interface{}, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errMsgPackShort
+	}
+	c := data[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), 1, nil
+	case c >= 0xe0:
+		return int64(int8(c)), 1, nil
+	case c&0xe0 == 0xa0:
+		return msgPackString(data, 1, int(c&0x1f))
+	case c&0xf0 == 0x90:
+		return msgPackArray(data, 1, int(c&0x0f))
+	case c&0xf0 == 0x80:
+		return msgPackMap(data, 1, int(c&0x0f))
+	}
+	switch c {
+	case 0xc0:
+		return nil, 1, nil
+	case 0xc2:
+		return false, 1, nil
+	case 0xc3:
+		return true, 1, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		size := 1 << (c - 0xcc)
+		if len(data) < 1+size {
+			return nil, 0, errMsgPackShort
+		}
+		u := msgPackUint(data[1 : 1+size])
+		if u > math.MaxInt64 {
+			return nil, 0, fmt.Errorf("msgpack: 整数%d超出int64的范围", u)
+		}
+		return int64(u), 1 + size, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		if len(data) < 1+size {
+			return nil, 0, errMsgPackShort
+		}
+		u := msgPackUint(data[1 : 1+size])
+		shift := uint(64 - size*8)
+		return int64(u<<shift) >> shift, 1 + size, nil
+	case 0xca:
+		if len(data) < 5 {
+			return nil, 0, errMsgPackShort
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data[1:]))), 5, nil
+	case 0xcb:
+		if len(data) < 9 {
+			return nil, 0, errMsgPackShort
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data[1:])), 9, nil
+	case 0xd9, 0xda, 0xdb:
+		n, size, err := msgPackReadLen(data, 1<<(c-0xd9))
+		if err != nil {
+			return nil, 0, err
+		}
+		return msgPackString(data, 1+size, n)
+	case 0xc4, 0xc5, 0xc6:
+		n, size, err := msgPackReadLen(data, 1<<(c-0xc4))
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(data) < 1+size+n {
+			return nil, 0, errMsgPackShort
+		}
+		bin := make([]byte, n)
+		copy(bin, data[1+size:])
+		return bin, 1 + size + n, nil
+	case 0xdc, 0xdd:
+		n, size, err := msgPackReadLen(data, 2<<(c-0xdc))
+		if err != nil {
+			return nil, 0, err
+		}
+		return msgPackArray(data, 1+size, n)
+	case 0xde, 0xdf:
+		n, size, err := msgPackReadLen(data, 2<<(c-0xde))
+		if err != nil {
+			return nil, 0, err
+		}
+		return msgPackMap(data, 1+size, n)
+	}
+	return nil, 0, fmt.Errorf("msgpack: 不支持的类型0x%02x", c)
+}
+
+func msgPackUint(data []byte) uint64 {
+	var result uint64
+	for _, b := range data {
+		result = result<<8 | uint64(b)
+	}
+	return result
+}
+
+func msgPackReadLen(data []byte, size int) (int, int, error) {
+	if len(data) < 1+size {
+		return 0, 0, errMsgPackShort
+	}
+	n := msgPackUint(data[1 : 1+size])
+	if n > uint64(len(data)) {
+		return 0, 0, errMsgPackShort
+	}
+	return int(n), size, nil
+}
+
+func msgPackString(data []byte, pos, n int) (interface{}, int, error) {
+	if len(data) < pos+n {
+		return nil, 0, errMsgPackShort
+	}
+	return string(data[pos : pos+n]), pos + n, nil
+}
+
+func msgPackArray(data []byte, pos, n int) (interface{}, int, error) {
+	if n > len(data)-pos {
+		return nil, 0, errMsgPackShort
+	}
+	result := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		item, size, err := msgPackDecode(data[pos:])
+		if err != nil {
+			return nil, 0, err
+		}
+		result[i] = item
+		pos += size
+	}
+	return result, pos, nil
+}
+
+func msgPackMap(data []byte, pos, n int) (interface{}, int, error) {
+	if n > (len(data)-pos)/2 {
+		return nil, 0, errMsgPackShort
+	}
+	result := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, size, err := msgPackDecode(data[pos:])
+		if err != nil {
+			return nil, 0, err
+		}
+		pos += size
+		value, size, err := msgPackDecode(data[pos:])
+		if err != nil {
+			return nil, 0, err
+		}
+		pos += size
+		if s, ok := key.(string); ok {
+			result[s] = value
+		} else {
+			result[fmt.Sprint(key)] = value
+		}
+	}
+	return result, pos, nil
+}
@@ -0,0 +1,139 @@
+package Network
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// 服务器主动推送的消息使用最高位为1的cmd和seq, 与请求的cmd和seq区分开
+const (
+	PUSH_CMD_FLAG uint32 = 0x80000000
+	PUSH_SEQ_FLAG uint32 = 0x80000000
+)
+
+// 推送消息的序号, 每条推送消息一个, 发送给多个连接时相同
+var pushSeq uint32
+
+// PushCmd 推送消息实际发送的cmd
+func PushCmd(cmd uint32) uint32 {
+	return cmd | PUSH_CMD_FLAG
+}
+
+// IsPushCmd 是否是推送消息的cmd
+func IsPushCmd(cmd uint32) bool {
+	return cmd&PUSH_CMD_FLAG != 0
+}
+
+// IsPushSeq 是否是推送消息的序号
+func IsPushSeq(seq uint32) bool {
+	return seq&PUSH_SEQ_FLAG != 0
+}
+
+// PushMessage 推送消息的格式
+type PushMessage struct {
+	Cmd  uint32      `json:"cmd"`
+	Seq  uint32      `json:"seq"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// 推送时每种编码方式只编码一次
+type pushEncoder struct {
+	msg   *PushMessage
+	cache map[pushFormat][]byte
+}
+
+// 按编码方式的名称区分, 用户的编码方式不一定可以作为map的键
+type pushFormat struct {
+	handle    *WebSocketRouteHandle
+	codec     string
+	headerCmd bool
+	compress  int
+}
+
+func newPushEncoder(cmd uint32, data interface{}) *pushEncoder {
+	if IsPushCmd(cmd) {
+		panic(fmt.Errorf("推送的cmd不能使用最高位: %d", cmd))
+	}
+	return &pushEncoder{
+		msg:   &PushMessage{Cmd: PushCmd(cmd), Seq: atomic.AddUint32(&pushSeq, 1) | PUSH_SEQ_FLAG, Data: data},
+		cache: make(map[pushFormat][]byte),
+	}
+}
+
+func (e *pushEncoder) encode(agent *WebSocketAgent) ([]byte, error) {
+	frameFormat := agent.frameFormat()
+	format := pushFormat{agent.RouteHandle, frameFormat.Codec.Name(), frameFormat.HeaderCmd, frameFormat.Compress}
+	if buff, ok := e.cache[format]; ok {
+		return buff, nil
+	}
+	buff, err := agent.marshal(e.msg.Cmd, e.msg.Seq, e.msg)
+	if err != nil {
+		return nil, err
+	}
+	e.cache[format] = buff
+	return buff, nil
+}
+
+// 发送给指定的连接, 返回成功的数量
+func (e *pushEncoder) send(agents []*WebSocketAgent) (int, error) {
+	count := 0
+	for _, agent := range agents {
+		buff, err := e.encode(agent)
+		if err != nil {
+			return count, err
+		}
+		if agent.SendByte(buff) == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Push 推送消息给当前连接
+func (e *WebSocketAgent) Push(cmd uint32, data interface{}) error {
+	buff, err := newPushEncoder(cmd, data).encode(e)
+	if err != nil {
+		return err
+	}
+	return e.SendByte(buff)
+}
+
+// Push 推送消息给指定的用户, 用户不在线时返回ErrSessionClosed
+func (e *SessionManager) Push(userId int64, cmd uint32, data interface{}) error {
+	agent := e.GetByUser(userId)
+	if agent == nil {
+		return ErrSessionClosed
+	}
+	return agent.Push(cmd, data)
+}
+
+// PushUsers 推送消息给多个用户, 返回发送成功的数量
+func (e *SessionManager) PushUsers(userIds []int64, cmd uint32, data interface{}) (int, error) {
+	e.lock.RLock()
+	agents := make([]*WebSocketAgent, 0, len(userIds))
+	for _, userId := range userIds {
+		if agent, ok := e.users[userId]; ok {
+			agents = append(agents, agent)
+		}
+	}
+	e.lock.RUnlock()
+	return newPushEncoder(cmd, data).send(agents)
+}
+
+// Broadcast 推送消息给所有连接, 返回发送成功的数量
+func (e *SessionManager) Broadcast(cmd uint32, data interface{}) (int, error) {
+	return e.BroadcastFilter(cmd, data, nil)
+}
+
+// BroadcastFilter 推送消息给filter返回true的连接, filter为nil时推送给所有连接
+func (e *SessionManager) BroadcastFilter(cmd uint32, data interface{}, filter func(agent *WebSocketAgent) bool) (int, error) {
+	encoder := newPushEncoder(cmd, data)
+	agents := make([]*WebSocketAgent, 0, e.Count())
+	e.Range(func(agent *WebSocketAgent) bool {
+		if filter == nil || filter(agent) {
+			agents = append(agents, agent)
+		}
+		return true
+	})
+	return encoder.send(agents)
+}
@@ -0,0 +1,340 @@
+package Network
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/team-zf/framework/config"
+	"github.com/team-zf/framework/modules"
+)
+
+// 统计被限制次数的时间窗口, 也是空闲的令牌桶被清理的时间
+const RATE_LIMIT_WINDOW = time.Minute
+
+// RateLimit 令牌桶的参数, Rate为每秒补充的令牌数, 为0时不限制
+type RateLimit struct {
+	Rate  float64
+	Burst int // 最多积累的令牌数, 为0时等于Rate
+}
+
+func (e RateLimit) burst() float64 {
+	if e.Burst > 0 {
+		return float64(e.Burst)
+	}
+	if e.Rate < 1 {
+		return 1
+	}
+	return e.Rate
+}
+
+// IRouteRateLimit 路由实现这个接口时, 用返回的参数代替默认的cmd限制
+type IRouteRateLimit interface {
+	RateLimit() RateLimit
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (e *tokenBucket) allow(limit RateLimit, now time.Time) bool {
+	e.refill(limit, now)
+	if e.tokens < 1 {
+		return false
+	}
+	e.tokens--
+	return true
+}
+
+func (e *tokenBucket) refill(limit RateLimit, now time.Time) {
+	if e.last.IsZero() {
+		e.tokens = limit.burst()
+	} else if e.tokens += now.Sub(e.last).Seconds() * limit.Rate; e.tokens > limit.burst() {
+		e.tokens = limit.burst()
+	}
+	e.last = now
+}
+
+// 一个IP在时间窗口内被限制的次数
+type offense struct {
+	count int
+	since time.Time
+}
+
+// RateLimiter 按连接, IP和cmd的令牌桶限制请求频率
+// 一个IP在一分钟内被限制banCount次后断开连接, banTime大于0时同时封禁这个IP
+type RateLimiter struct {
+	session  RateLimit
+	ip       RateLimit
+	cmd      RateLimit
+	cmds     map[uint32]RateLimit // 按cmd覆盖的限制
+	banCount int
+	banTime  time.Duration
+	proxies  []*net.IPNet // 可信的代理
+
+	lock      sync.Mutex
+	buckets   map[string]*tokenBucket
+	limits    map[string]RateLimit // 每个令牌桶使用的参数, 清理时使用
+	offenses  map[string]*offense
+	bans      map[string]time.Time
+	lastSweep time.Time
+
+	limitedCount  int64 // 被限制的请求总数
+	punishedCount int64 // 被断开的次数
+}
+
+// NewRateLimiter 按配置创建, conf为nil时返回nil, 表示不限制
+func NewRateLimiter(conf *config.RateLimitConfig) *RateLimiter {
+	if conf == nil {
+		return nil
+	}
+	result := &RateLimiter{
+		session:  RateLimit{conf.Session.Rate, conf.Session.Burst},
+		ip:       RateLimit{conf.IP.Rate, conf.IP.Burst},
+		cmd:      RateLimit{conf.Cmd.Rate, conf.Cmd.Burst},
+		cmds:     make(map[uint32]RateLimit),
+		banCount: conf.BanCount,
+		banTime:  time.Duration(conf.BanTime) * time.Second,
+		buckets:  make(map[string]*tokenBucket),
+		limits:   make(map[string]RateLimit),
+		offenses: make(map[string]*offense),
+		bans:     make(map[string]time.Time),
+	}
+	for key, limit := range conf.Cmds {
+		var cmd uint32
+		if _, err := fmt.Sscanf(key, "%d", &cmd); err == nil {
+			result.cmds[cmd] = RateLimit{limit.Rate, limit.Burst}
+		}
+	}
+	for _, cidr := range conf.TrustedProxies {
+		if network, err := config.ParseCIDR(cidr); err == nil {
+			result.proxies = append(result.proxies, network)
+		}
+	}
+	return result
+}
+
+// SetCmdLimit 覆盖指定cmd的限制, 优先于路由的IRouteRateLimit
+func (e *RateLimiter) SetCmdLimit(cmd uint32, limit RateLimit) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.cmds[cmd] = limit
+}
+
+// Allow 检查一个请求, session为空时按IP限制连接
+// ok为false时请求被限制, punish为true时应该断开连接
+func (e *RateLimiter) Allow(ip, session string, route IRoute) (ok bool, punish bool) {
+	cmd := route.GetCmd()
+	cmdLimit, found := e.cmdLimit(cmd)
+	if !found {
+		if r, ok := route.(IRouteRateLimit); ok {
+			cmdLimit = r.RateLimit()
+		}
+	}
+	if session == "" {
+		session = ip
+	}
+
+	now := time.Now()
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.sweep(now)
+	if e.banned(ip, now) {
+		return false, true
+	}
+	// 三个令牌桶都有令牌时才扣除, 被限制的请求不消耗令牌
+	keys := [...]string{"s:" + session, "ip:" + ip, fmt.Sprintf("c:%s:%d", session, cmd)}
+	limits := [...]RateLimit{e.session, e.ip, cmdLimit}
+	for i, limit := range limits {
+		if limit.Rate > 0 && e.bucket(keys[i], limit, now).tokens < 1 {
+			atomic.AddInt64(&e.limitedCount, 1)
+			return false, e.offend(ip, now)
+		}
+	}
+	for i, limit := range limits {
+		if limit.Rate > 0 {
+			e.buckets[keys[i]].allow(limit, now)
+		}
+	}
+	return true, false
+}
+
+func (e *RateLimiter) cmdLimit(cmd uint32) (RateLimit, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if limit, ok := e.cmds[cmd]; ok {
+		return limit, true
+	}
+	return e.cmd, false
+}
+
+// 取得令牌桶并补充令牌
+func (e *RateLimiter) bucket(key string, limit RateLimit, now time.Time) *tokenBucket {
+	bucket, ok := e.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{}
+		e.buckets[key] = bucket
+	}
+	e.limits[key] = limit
+	bucket.refill(limit, now)
+	return bucket
+}
+
+// 记录一次被限制, 返回是否达到了断开的次数
+func (e *RateLimiter) offend(ip string, now time.Time) bool {
+	if e.banCount <= 0 {
+		return false
+	}
+	item, ok := e.offenses[ip]
+	if !ok || now.Sub(item.since) > RATE_LIMIT_WINDOW {
+		item = &offense{since: now}
+		e.offenses[ip] = item
+	}
+	item.count++
+	if item.count < e.banCount {
+		return false
+	}
+	delete(e.offenses, ip)
+	atomic.AddInt64(&e.punishedCount, 1)
+	if e.banTime > 0 {
+		e.bans[ip] = now.Add(e.banTime)
+	}
+	return true
+}
+
+func (e *RateLimiter) banned(ip string, now time.Time) bool {
+	until, ok := e.bans[ip]
+	if ok && now.After(until) {
+		delete(e.bans, ip)
+		return false
+	}
+	return ok
+}
+
+// Banned 这个IP是否被封禁
+func (e *RateLimiter) Banned(ip string) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.banned(ip, time.Now())
+}
+
+// Ban 封禁IP, d为0时解除封禁
+func (e *RateLimiter) Ban(ip string, d time.Duration) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if d > 0 {
+		e.bans[ip] = time.Now().Add(d)
+	} else {
+		delete(e.bans, ip)
+	}
+}
+
+// 清理已经补满的令牌桶, 过期的记录和封禁
+func (e *RateLimiter) sweep(now time.Time) {
+	if now.Sub(e.lastSweep) < RATE_LIMIT_WINDOW {
+		return
+	}
+	e.lastSweep = now
+	for key, bucket := range e.buckets {
+		limit := e.limits[key]
+		if now.Sub(bucket.last) > RATE_LIMIT_WINDOW && bucket.tokens+now.Sub(bucket.last).Seconds()*limit.Rate >= limit.burst() {
+			delete(e.buckets, key)
+			delete(e.limits, key)
+		}
+	}
+	for ip, item := range e.offenses {
+		if now.Sub(item.since) > RATE_LIMIT_WINDOW {
+			delete(e.offenses, ip)
+		}
+	}
+	for ip, until := range e.bans {
+		if now.After(until) {
+			delete(e.bans, ip)
+		}
+	}
+}
+
+// RateLimiterStats 限流的统计
+type RateLimiterStats struct {
+	Limited  int64 // 被限制的请求总数
+	Punished int64 // 被断开的次数
+	Banned   int   // 当前封禁的IP数量
+	Buckets  int   // 当前的令牌桶数量
+}
+
+func (e *RateLimiter) Stats() RateLimiterStats {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return RateLimiterStats{
+		Limited:  atomic.LoadInt64(&e.limitedCount),
+		Punished: atomic.LoadInt64(&e.punishedCount),
+		Banned:   len(e.bans),
+		Buckets:  len(e.buckets),
+	}
+}
+
+// 在模块状态中加上限流的统计, 没有限流时不加
+func (e *RateLimiter) addStatus(status *modules.ModuleStatus) *modules.ModuleStatus {
+	if e == nil {
+		return status
+	}
+	stats := e.Stats()
+	return status.
+		Counter("limitedCount", stats.Limited).
+		Counter("punishedCount", stats.Punished).
+		Gauge("bannedCount", float64(stats.Banned))
+}
+
+// ClientIP 取得限流和封禁使用的客户端IP
+// 请求来自可信的代理时, 从X-Forwarded-For中由右向左取第一个不是可信代理的IP, 没有时使用X-Real-IP
+// 其它请求的这两个请求头可以伪造, 直接使用连接的地址
+func (e *RateLimiter) ClientIP(req *http.Request) string {
+	ip := remoteIP(req.RemoteAddr)
+	if e == nil || !e.trusted(ip) {
+		return ip
+	}
+	if header := req.Header.Values("X-Forwarded-For"); len(header) > 0 {
+		hops := strings.Split(strings.Join(header, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				// 格式错误时之前的内容都不可信
+				return ip
+			}
+			if ip = hop; !e.trusted(hop) {
+				return hop
+			}
+		}
+		return ip
+	}
+	if realIP := strings.TrimSpace(req.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return ip
+}
+
+func (e *RateLimiter) trusted(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, network := range e.proxies {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// 取得地址中的IP, 没有端口时原样返回
+func remoteIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
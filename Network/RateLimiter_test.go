@@ -0,0 +1,106 @@
+package Network
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/team-zf/framework/config"
+)
+
+type limitTestRoute struct {
+	WebSocketRoute
+}
+
+func (e *limitTestRoute) RateLimit() RateLimit {
+	return RateLimit{Rate: 0.001, Burst: 1}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(&config.RateLimitConfig{
+		Cmd:      config.LimitConfig{Rate: 0.001, Burst: 2},
+		Cmds:     map[string]config.LimitConfig{"8": {Rate: 1000}},
+		BanCount: 3,
+		BanTime:  60,
+	})
+	route := &WebSocketRoute{Cmd: 7}
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow("1.2.3.4", "1", route); !ok {
+			t.Fatalf("第%d个请求不应该被限制", i+1)
+		}
+	}
+	// 不同的连接和cmd分别计算
+	if ok, _ := limiter.Allow("1.2.3.4", "2", route); !ok {
+		t.Fatal("其它连接的请求不应该被限制")
+	}
+	for i := 0; i < 10; i++ {
+		if ok, _ := limiter.Allow("1.2.3.4", "1", &WebSocketRoute{Cmd: 8}); !ok {
+			t.Fatal("配置中覆盖的cmd不应该被限制")
+		}
+	}
+	// 路由覆盖默认的限制
+	override := &limitTestRoute{}
+	override.Cmd = 9
+	if ok, _ := limiter.Allow("5.6.7.8", "3", override); !ok {
+		t.Fatal("第1个请求不应该被限制")
+	}
+	if ok, _ := limiter.Allow("5.6.7.8", "3", override); ok {
+		t.Fatal("超过路由的限制时应该被限制")
+	}
+
+	// 第3次被限制时断开并封禁
+	for i := 1; i <= 3; i++ {
+		ok, punish := limiter.Allow("1.2.3.4", "1", route)
+		if ok || punish != (i == 3) {
+			t.Fatalf("第%d次超过限制的结果不正确: %v %v", i, ok, punish)
+		}
+	}
+	if !limiter.Banned("1.2.3.4") || limiter.Banned("5.6.7.8") {
+		t.Fatal("封禁的IP不正确")
+	}
+	if ok, _ := limiter.Allow("1.2.3.4", "2", &WebSocketRoute{Cmd: 8}); ok {
+		t.Fatal("封禁的IP不能再请求")
+	}
+	stats := limiter.Stats()
+	if stats.Limited != 4 || stats.Punished != 1 || stats.Banned != 1 {
+		t.Fatalf("统计不正确: %+v", stats)
+	}
+}
+
+func TestRateLimiterClientIP(t *testing.T) {
+	limiter := NewRateLimiter(&config.RateLimitConfig{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"}})
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		realIP string
+		want   string
+	}{
+		{"没有代理", "1.2.3.4:5000", "", "", "1.2.3.4"},
+		{"不可信的地址不使用请求头", "1.2.3.4:5000", "9.9.9.9", "8.8.8.8", "1.2.3.4"},
+		{"可信的代理", "10.1.2.3:5000", "9.9.9.9", "", "9.9.9.9"},
+		{"跳过可信的代理", "10.1.2.3:5000", "6.6.6.6, 9.9.9.9, 192.168.1.1", "", "9.9.9.9"},
+		{"客户端伪造的部分不使用", "10.1.2.3:5000", "6.6.6.6, 9.9.9.9", "", "9.9.9.9"},
+		{"全部是可信的代理", "10.1.2.3:5000", "10.0.0.1, 10.0.0.2", "", "10.0.0.1"},
+		{"格式错误", "10.1.2.3:5000", "9.9.9.9, cdn", "", "10.1.2.3"},
+		{"X-Real-IP", "192.168.1.1:5000", "", "7.7.7.7", "7.7.7.7"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = test.remote
+		if test.xff != "" {
+			req.Header.Set("X-Forwarded-For", test.xff)
+		}
+		if test.realIP != "" {
+			req.Header.Set("X-Real-IP", test.realIP)
+		}
+		if ip := limiter.ClientIP(req); ip != test.want {
+			t.Errorf("%s: %s", test.name, ip)
+		}
+	}
+	// 没有限流时使用连接的地址
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "9.9.9.9")
+	if ip := (*RateLimiter)(nil).ClientIP(req); ip != remoteIP(req.RemoteAddr) {
+		t.Fatalf("没有限流时的IP不正确: %s", ip)
+	}
+}
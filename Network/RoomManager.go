@@ -0,0 +1,257 @@
+package Network
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	ErrRoomExist    = errors.New("房间已存在")
+	ErrRoomNotFound = errors.New("房间不存在")
+)
+
+// Room 把多个连接分成一组, 如公会聊天, 组队战斗, 世界频道
+type Room struct {
+	id       string
+	manager  *RoomManager
+	members  map[*WebSocketAgent]bool // 由manager.lock保护
+	attrLock sync.RWMutex
+	attrs    map[string]interface{}
+}
+
+func (e *Room) ID() string {
+	return e.id
+}
+
+// Join 加入房间, 房间已销毁时返回ErrRoomNotFound
+func (e *Room) Join(agent *WebSocketAgent) error {
+	return e.manager.join(e, agent)
+}
+
+func (e *Room) Leave(agent *WebSocketAgent) {
+	e.manager.leave(e, agent)
+}
+
+func (e *Room) Has(agent *WebSocketAgent) bool {
+	e.manager.lock.RLock()
+	defer e.manager.lock.RUnlock()
+	return e.members[agent]
+}
+
+func (e *Room) Count() int {
+	e.manager.lock.RLock()
+	defer e.manager.lock.RUnlock()
+	return len(e.members)
+}
+
+// Members 当前所有成员的副本
+func (e *Room) Members() []*WebSocketAgent {
+	e.manager.lock.RLock()
+	defer e.manager.lock.RUnlock()
+	result := make([]*WebSocketAgent, 0, len(e.members))
+	for agent := range e.members {
+		result = append(result, agent)
+	}
+	return result
+}
+
+// Broadcast 推送消息给房间内除exclude以外的成员, 返回发送成功的数量
+func (e *Room) Broadcast(cmd uint32, data interface{}, exclude ...*WebSocketAgent) (int, error) {
+	encoder := newPushEncoder(cmd, data)
+	members := e.Members()
+	agents := members[:0]
+	for _, agent := range members {
+		skip := false
+		for _, ex := range exclude {
+			if agent == ex {
+				skip = true
+				break
+			}
+		}
+		if !skip {
+			agents = append(agents, agent)
+		}
+	}
+	return encoder.send(agents)
+}
+
+// Set 保存房间的自定义数据
+func (e *Room) Set(key string, v interface{}) {
+	e.attrLock.Lock()
+	defer e.attrLock.Unlock()
+	e.attrs[key] = v
+}
+
+func (e *Room) Get(key string) (interface{}, bool) {
+	e.attrLock.RLock()
+	defer e.attrLock.RUnlock()
+	v, ok := e.attrs[key]
+	return v, ok
+}
+
+func (e *Room) Delete(key string) {
+	e.attrLock.Lock()
+	defer e.attrLock.Unlock()
+	delete(e.attrs, key)
+}
+
+// RoomManager 管理所有房间, 连接断开时自动离开所在的房间
+type RoomManager struct {
+	lock   sync.RWMutex
+	rooms  map[string]*Room
+	joined map[*WebSocketAgent]map[*Room]bool // 每个连接加入的房间
+}
+
+func NewRoomManager() *RoomManager {
+	return &RoomManager{
+		rooms:  make(map[string]*Room),
+		joined: make(map[*WebSocketAgent]map[*Room]bool),
+	}
+}
+
+// Create 创建房间, 已存在时返回ErrRoomExist
+func (e *RoomManager) Create(id string) (*Room, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if _, ok := e.rooms[id]; ok {
+		return nil, ErrRoomExist
+	}
+	return e.create(id), nil
+}
+
+// GetOrCreate 取得房间, 不存在时创建
+func (e *RoomManager) GetOrCreate(id string) *Room {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if room, ok := e.rooms[id]; ok {
+		return room
+	}
+	return e.create(id)
+}
+
+func (e *RoomManager) create(id string) *Room {
+	room := &Room{
+		id:      id,
+		manager: e,
+		members: make(map[*WebSocketAgent]bool),
+		attrs:   make(map[string]interface{}),
+	}
+	e.rooms[id] = room
+	return room
+}
+
+func (e *RoomManager) Get(id string) *Room {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.rooms[id]
+}
+
+// Destroy 销毁房间, 所有成员离开
+func (e *RoomManager) Destroy(id string) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	room, ok := e.rooms[id]
+	if !ok {
+		return false
+	}
+	for agent := range room.members {
+		e.removeJoined(room, agent)
+	}
+	room.members = make(map[*WebSocketAgent]bool)
+	delete(e.rooms, id)
+	return true
+}
+
+// Join 加入指定的房间, 房间不存在时返回ErrRoomNotFound
+func (e *RoomManager) Join(id string, agent *WebSocketAgent) error {
+	room := e.Get(id)
+	if room == nil {
+		return ErrRoomNotFound
+	}
+	return e.join(room, agent)
+}
+
+func (e *RoomManager) Leave(id string, agent *WebSocketAgent) {
+	if room := e.Get(id); room != nil {
+		e.leave(room, agent)
+	}
+}
+
+// LeaveAll 离开所有房间, 连接断开时自动调用
+func (e *RoomManager) LeaveAll(agent *WebSocketAgent) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for room := range e.joined[agent] {
+		delete(room.members, agent)
+	}
+	delete(e.joined, agent)
+}
+
+// RoomsOf 连接加入的所有房间
+func (e *RoomManager) RoomsOf(agent *WebSocketAgent) []*Room {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	result := make([]*Room, 0, len(e.joined[agent]))
+	for room := range e.joined[agent] {
+		result = append(result, room)
+	}
+	return result
+}
+
+// Count 房间数量
+func (e *RoomManager) Count() int {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return len(e.rooms)
+}
+
+// Range 遍历所有房间, fn返回false时停止
+func (e *RoomManager) Range(fn func(room *Room) bool) {
+	e.lock.RLock()
+	rooms := make([]*Room, 0, len(e.rooms))
+	for _, room := range e.rooms {
+		rooms = append(rooms, room)
+	}
+	e.lock.RUnlock()
+	for _, room := range rooms {
+		if !fn(room) {
+			return
+		}
+	}
+}
+
+func (e *RoomManager) join(room *Room, agent *WebSocketAgent) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.rooms[room.id] != room {
+		return ErrRoomNotFound
+	}
+	// 已经断开的连接不再加入, 否则无法自动清理
+	if agent.Reason() != 0 {
+		return ErrSessionClosed
+	}
+	room.members[agent] = true
+	rooms, ok := e.joined[agent]
+	if !ok {
+		rooms = make(map[*Room]bool)
+		e.joined[agent] = rooms
+	}
+	rooms[room] = true
+	return nil
+}
+
+func (e *RoomManager) leave(room *Room, agent *WebSocketAgent) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	delete(room.members, agent)
+	e.removeJoined(room, agent)
+}
+
+func (e *RoomManager) removeJoined(room *Room, agent *WebSocketAgent) {
+	if rooms, ok := e.joined[agent]; ok {
+		delete(rooms, room)
+		if len(rooms) == 0 {
+			delete(e.joined, agent)
+		}
+	}
+}
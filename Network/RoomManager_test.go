@@ -0,0 +1,187 @@
+package Network
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 不连接网络的测试连接, 发送的消息留在队列中
+func newRoomTestAgent(handle *WebSocketRouteHandle) *WebSocketAgent {
+	agent := newWebSocketAgent(nil, handle)
+	agent.headerCmd = true
+	agent.writer = newWriteQueue(16, OverflowDrop, 0)
+	return agent
+}
+
+func TestRoomJoinLeaveDestroy(t *testing.T) {
+	handle := NewWebSocketRouteHandle()
+	a, b := newRoomTestAgent(handle), newRoomTestAgent(handle)
+	rooms := NewRoomManager()
+	room, err := rooms.Create("guild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rooms.Create("guild"); err != ErrRoomExist {
+		t.Fatalf("重复创建应该返回ErrRoomExist: %v", err)
+	}
+	if rooms.GetOrCreate("guild") != room {
+		t.Fatal("GetOrCreate应该返回已有的房间")
+	}
+	world := rooms.GetOrCreate("world")
+	if err := room.Join(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := rooms.Join("guild", b); err != nil {
+		t.Fatal(err)
+	}
+	world.Join(a)
+	if !room.Has(a) || !room.Has(b) || room.Count() != 2 || len(rooms.RoomsOf(a)) != 2 {
+		t.Fatalf("加入房间的结果不正确: %d %d", room.Count(), len(rooms.RoomsOf(a)))
+	}
+
+	room.Leave(a)
+	if room.Has(a) || room.Count() != 1 || len(rooms.RoomsOf(a)) != 1 {
+		t.Fatal("离开房间的结果不正确")
+	}
+	if !rooms.Destroy("guild") || rooms.Destroy("guild") {
+		t.Fatal("只能销毁一次")
+	}
+	if rooms.Get("guild") != nil || rooms.Count() != 1 || room.Count() != 0 || len(rooms.RoomsOf(b)) != 0 {
+		t.Fatal("销毁房间后成员没有离开")
+	}
+	if err := room.Join(b); err != ErrRoomNotFound {
+		t.Fatalf("加入已销毁的房间应该返回ErrRoomNotFound: %v", err)
+	}
+	if err := rooms.Join("guild", b); err != ErrRoomNotFound {
+		t.Fatalf("加入不存在的房间应该返回ErrRoomNotFound: %v", err)
+	}
+
+	// 已断开的连接不能再加入
+	b.setReason(DisconnectEOF)
+	if err := world.Join(b); err != ErrSessionClosed {
+		t.Fatalf("断开后加入应该返回ErrSessionClosed: %v", err)
+	}
+	rooms.LeaveAll(a)
+	if world.Count() != 0 || len(rooms.RoomsOf(a)) != 0 {
+		t.Fatal("LeaveAll之后还在房间中")
+	}
+}
+
+func TestRoomBroadcast(t *testing.T) {
+	handle := NewWebSocketRouteHandle()
+	room := NewRoomManager().GetOrCreate("team")
+	agents := []*WebSocketAgent{newRoomTestAgent(handle), newRoomTestAgent(handle), newRoomTestAgent(handle)}
+	for _, agent := range agents {
+		room.Join(agent)
+	}
+	count, err := room.Broadcast(9, "hi", agents[1])
+	if err != nil || count != 2 {
+		t.Fatalf("广播的数量不正确: %d %v", count, err)
+	}
+	if agents[0].QueueLen() != 1 || agents[1].QueueLen() != 0 || agents[2].QueueLen() != 1 {
+		t.Fatal("排除的连接不应该收到广播")
+	}
+	frame, err := ParseFrame((<-agents[0].writer.queue).buff, FRAME_MAX_SIZE)
+	if err != nil || frame.Cmd != PushCmd(9) || !IsPushSeq(frame.Seq) {
+		t.Fatalf("广播的消息不正确: %+v %v", frame, err)
+	}
+	if count, _ = room.Broadcast(9, "hi", agents...); count != 0 {
+		t.Fatalf("全部排除时不应该发送: %d", count)
+	}
+}
+
+// 连接断开时自动离开所有房间, 之后不能再加入
+func TestRoomLeaveOnDisconnect(t *testing.T) {
+	mod, addr, reasons, stop := startTestServer(t)
+	defer stop()
+	client, err := DialWebSocket(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetReadTimeout(3 * time.Second)
+	client.Send(7, map[string]interface{}{"name": "a"})
+	receiveResponse(t, client)
+
+	var agent *WebSocketAgent
+	mod.Sessions().Range(func(v *WebSocketAgent) bool {
+		agent = v
+		return false
+	})
+	room := mod.Rooms().GetOrCreate("world")
+	if err := room.Join(agent); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	waitReason(t, reasons)
+	if room.Has(agent) || len(mod.Rooms().RoomsOf(agent)) != 0 {
+		t.Fatal("断开后没有离开房间")
+	}
+	if err := room.Join(agent); err != ErrSessionClosed {
+		t.Fatalf("断开后加入应该返回ErrSessionClosed: %v", err)
+	}
+}
+
+// 用 go test -race 运行, 检查并发时的数据竞争和房间与连接记录的一致
+func TestRoomConcurrent(t *testing.T) {
+	handle := NewWebSocketRouteHandle()
+	rooms := NewRoomManager()
+	agents := make([]*WebSocketAgent, 8)
+	for i := range agents {
+		agents[i] = newRoomTestAgent(handle)
+	}
+	wg := sync.WaitGroup{}
+	for i := range agents {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(i)))
+			for n := 0; n < 500; n++ {
+				agent := agents[rnd.Intn(len(agents))]
+				id := fmt.Sprintf("room%d", rnd.Intn(4))
+				switch rnd.Intn(7) {
+				case 0, 1:
+					rooms.GetOrCreate(id).Join(agent)
+				case 2:
+					rooms.Leave(id, agent)
+				case 3:
+					rooms.LeaveAll(agent)
+				case 4:
+					if room := rooms.Get(id); room != nil {
+						room.Broadcast(1, n, agent)
+					}
+				case 5:
+					rooms.Destroy(id)
+				case 6:
+					for _, room := range rooms.RoomsOf(agent) {
+						room.Members()
+						room.Set("n", n)
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, agent := range agents {
+		for _, room := range rooms.RoomsOf(agent) {
+			if rooms.Get(room.ID()) != room || !room.Has(agent) {
+				t.Fatalf("连接记录的房间%s不正确", room.ID())
+			}
+		}
+	}
+	rooms.Range(func(room *Room) bool {
+		for _, agent := range room.Members() {
+			found := false
+			for _, v := range rooms.RoomsOf(agent) {
+				found = found || v == room
+			}
+			if !found {
+				t.Fatalf("房间%s的成员没有记录", room.ID())
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,53 @@
+package Network
+
+// 每个连接缓存最近回应过的请求数量, 重发这些请求时直接返回缓存的回应
+const SEQ_CACHE_SIZE = 16
+
+// 请求序号的状态, 只在连接的接收协程中使用
+// 客户端的序号从1开始, 每个请求加1, 为0时表示不带序号
+type seqState struct {
+	last    uint32 // 最后收到的序号
+	current uint32 // 正在处理的请求的序号
+	replies [SEQ_CACHE_SIZE]seqReply
+}
+
+type seqReply struct {
+	seq  uint32
+	buff []byte
+}
+
+// 检查请求的序号, ok为false时是乱序或重放的请求
+// 已经回应过的请求返回缓存的回应
+func (e *seqState) check(seq uint32) (cached []byte, ok bool) {
+	if IsPushSeq(seq) {
+		return nil, false
+	}
+	if seq == e.last+1 {
+		e.last = seq
+		return nil, true
+	}
+	if seq <= e.last {
+		if reply := e.replies[seq%SEQ_CACHE_SIZE]; reply.seq == seq && reply.buff != nil {
+			return reply.buff, true
+		}
+	}
+	return nil, false
+}
+
+// 缓存回应, 重发时直接返回
+func (e *seqState) cache(seq uint32, buff []byte) {
+	e.replies[seq%SEQ_CACHE_SIZE] = seqReply{seq: seq, buff: buff}
+}
+
+// 回应当前的请求, 请求带序号时回应中带同样的序号并缓存
+func (e *WebSocketAgent) reply(cmd uint32, data interface{}) error {
+	seq := e.seqs.current
+	buff, err := e.marshal(cmd, seq, data)
+	if err != nil {
+		return err
+	}
+	if seq != 0 {
+		e.seqs.cache(seq, buff)
+	}
+	return e.SendByte(buff)
+}
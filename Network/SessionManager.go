@@ -0,0 +1,166 @@
+package Network
+
+import (
+	"errors"
+	"github.com/team-zf/framework/messages"
+	"sync"
+)
+
+// DuplicateLoginPolicy 同一个用户重复登录时的处理方式
+type DuplicateLoginPolicy int
+
+const (
+	DuplicateKickOld   DuplicateLoginPolicy = iota // 踢掉原来的连接
+	DuplicateRejectNew                             // 拒绝新的登录
+)
+
+// PUSH_CMD_KICK 被踢下线时推送的cmd(实际发送时加上PUSH_CMD_FLAG)
+// 推送的data中code为原因: RC_Kicked 或 RC_DuplicateLogin
+const PUSH_CMD_KICK uint32 = 0
+
+var (
+	ErrDuplicateLogin = errors.New("帐号已在其它地方登录")
+	ErrSessionClosed  = errors.New("连接已断开")
+)
+
+// SessionManager 管理所有的连接, 可以按连接编号或用户查找
+// 多个WebSocketModule可以共用一个SessionManager
+type SessionManager struct {
+	lock     sync.RWMutex
+	sessions map[uint64]*WebSocketAgent
+	users    map[int64]*WebSocketAgent
+	policy   DuplicateLoginPolicy
+}
+
+func NewSessionManager(policy DuplicateLoginPolicy) *SessionManager {
+	return &SessionManager{
+		sessions: make(map[uint64]*WebSocketAgent),
+		users:    make(map[int64]*WebSocketAgent),
+		policy:   policy,
+	}
+}
+
+func (e *SessionManager) add(agent *WebSocketAgent) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	agent.manager = e
+	e.sessions[agent.ID()] = agent
+}
+
+func (e *SessionManager) remove(agent *WebSocketAgent) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	delete(e.sessions, agent.ID())
+	if userId := agent.UserID(); userId != 0 && e.users[userId] == agent {
+		delete(e.users, userId)
+	}
+}
+
+// Bind 把连接绑定到用户, 同一用户已有连接时按重复登录的策略处理
+func (e *SessionManager) Bind(agent *WebSocketAgent, userId int64) error {
+	if userId == 0 {
+		e.Unbind(agent)
+		return nil
+	}
+	e.lock.Lock()
+	if _, ok := e.sessions[agent.ID()]; !ok {
+		e.lock.Unlock()
+		return ErrSessionClosed
+	}
+	old := e.users[userId]
+	if old != nil && old != agent && e.policy == DuplicateRejectNew {
+		e.lock.Unlock()
+		return ErrDuplicateLogin
+	}
+	if prev := agent.UserID(); prev != 0 && prev != userId && e.users[prev] == agent {
+		delete(e.users, prev)
+	}
+	agent.setUserID(userId)
+	e.users[userId] = agent
+	e.lock.Unlock()
+
+	if old != nil && old != agent {
+		old.setUserID(0)
+		e.kick(old, DisconnectDuplicateLogin)
+	}
+	return nil
+}
+
+// Unbind 解除连接与用户的绑定, 连接不会断开
+func (e *SessionManager) Unbind(agent *WebSocketAgent) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if userId := agent.UserID(); userId != 0 && e.users[userId] == agent {
+		delete(e.users, userId)
+	}
+	agent.setUserID(0)
+}
+
+func (e *SessionManager) Get(id uint64) *WebSocketAgent {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.sessions[id]
+}
+
+func (e *SessionManager) GetByUser(userId int64) *WebSocketAgent {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.users[userId]
+}
+
+// Range 遍历所有连接, fn返回false时停止
+func (e *SessionManager) Range(fn func(agent *WebSocketAgent) bool) {
+	e.lock.RLock()
+	agents := make([]*WebSocketAgent, 0, len(e.sessions))
+	for _, agent := range e.sessions {
+		agents = append(agents, agent)
+	}
+	e.lock.RUnlock()
+	for _, agent := range agents {
+		if !fn(agent) {
+			return
+		}
+	}
+}
+
+// Count 连接数
+func (e *SessionManager) Count() int {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return len(e.sessions)
+}
+
+// UserCount 已绑定用户的连接数
+func (e *SessionManager) UserCount() int {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return len(e.users)
+}
+
+// Kick 按连接编号踢下线
+func (e *SessionManager) Kick(id uint64) bool {
+	if agent := e.Get(id); agent != nil {
+		e.kick(agent, DisconnectKicked)
+		return true
+	}
+	return false
+}
+
+// KickUser 按用户踢下线
+func (e *SessionManager) KickUser(userId int64) bool {
+	if agent := e.GetByUser(userId); agent != nil {
+		e.kick(agent, DisconnectKicked)
+		return true
+	}
+	return false
+}
+
+// 推送被踢的原因后断开, 推送使用保留的cmd和序号, 不会与请求的回应混淆
+func (e *SessionManager) kick(agent *WebSocketAgent, reason DisconnectReason) {
+	code := messages.RC_Kicked
+	if reason == DisconnectDuplicateLogin {
+		code = messages.RC_DuplicateLogin
+	}
+	agent.Push(PUSH_CMD_KICK, map[string]interface{}{"code": code, "reason": reason.String()})
+	agent.CloseAfterWrite(reason)
+}
@@ -1,20 +1,258 @@
 package Network
 
-import "golang.org/x/net/websocket"
+import (
+	"crypto/ed25519"
+	"golang.org/x/net/websocket"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 连接编号, 进程内唯一
+var sessionSeq uint64
 
 type WebSocketAgent struct {
-	Conn        *websocket.Conn
-	RouteHandle *WebSocketRouteHandle
+	Conn         *websocket.Conn
+	RouteHandle  *WebSocketRouteHandle
+	WriteTimeout time.Duration // 写超时, 为0时不限制
+	reason       int32         // 断开的原因, 以第一次设置的为准
+	id           uint64        // 连接编号
+	userId       int64         // 绑定的用户, 为0时未绑定
+	manager      *SessionManager
+	attrLock     sync.RWMutex
+	attrs        map[string]interface{}
+	writer       *writeQueue    // 发送队列, 为nil时直接写入连接
+	codec        ICodec         // 客户端协商的编码方式, 为nil时使用RouteHandle的默认编码
+	headerCmd    bool           // 客户端是否使用消息头中带cmd的格式
+	compress     int            // 消息体达到这个长度时压缩, 为0时客户端不支持压缩
+	sendLock     sync.Mutex     // 没有发送队列时保证加密和写入的顺序
+	cipher       *SessionCipher // 握手后的加密方式, 为nil时不加密
+	seqs         seqState       // 请求序号的状态
+}
+
+func newWebSocketAgent(conn *websocket.Conn, routeHandle *WebSocketRouteHandle) *WebSocketAgent {
+	return &WebSocketAgent{
+		Conn:        conn,
+		RouteHandle: routeHandle,
+		id:          atomic.AddUint64(&sessionSeq, 1),
+		attrs:       make(map[string]interface{}),
+	}
+}
+
+// ID 连接编号
+func (e *WebSocketAgent) ID() uint64 {
+	return e.id
+}
+
+// UserID 绑定的用户, 为0时未绑定
+func (e *WebSocketAgent) UserID() int64 {
+	return atomic.LoadInt64(&e.userId)
+}
+
+func (e *WebSocketAgent) setUserID(v int64) {
+	atomic.StoreInt64(&e.userId, v)
+}
+
+// Bind 登录成功后把连接绑定到用户
+func (e *WebSocketAgent) Bind(userId int64) error {
+	if e.manager == nil {
+		e.setUserID(userId)
+		return nil
+	}
+	return e.manager.Bind(e, userId)
+}
+
+// Unbind 解除与用户的绑定
+func (e *WebSocketAgent) Unbind() {
+	if e.manager == nil {
+		e.setUserID(0)
+		return
+	}
+	e.manager.Unbind(e)
+}
+
+// Set 保存连接上的自定义数据
+func (e *WebSocketAgent) Set(key string, v interface{}) {
+	e.attrLock.Lock()
+	defer e.attrLock.Unlock()
+	e.attrs[key] = v
+}
+
+func (e *WebSocketAgent) Get(key string) (interface{}, bool) {
+	e.attrLock.RLock()
+	defer e.attrLock.RUnlock()
+	v, ok := e.attrs[key]
+	return v, ok
+}
+
+func (e *WebSocketAgent) Delete(key string) {
+	e.attrLock.Lock()
+	defer e.attrLock.Unlock()
+	delete(e.attrs, key)
 }
 
+// SendData 编码后发送, cmd从data中取得
 func (e *WebSocketAgent) SendData(data interface{}) error {
-	buff, err := e.RouteHandle.Marshal(data)
+	return e.SendCmd(cmdOf(data), data)
+}
+
+// SendCmd 按客户端协商的格式编码后发送
+func (e *WebSocketAgent) SendCmd(cmd uint32, data interface{}) error {
+	buff, err := e.marshal(cmd, 0, data)
 	if err != nil {
 		return err
 	}
 	return e.SendByte(buff)
 }
 
+// 不经过路由直接回应结果代码
+func (e *WebSocketAgent) replyCode(cmd, seq, code uint32) error {
+	buff, err := e.marshal(cmd, seq, &WebSocketResponse{
+		Cmd:  cmd,
+		Seq:  seq,
+		Code: code,
+	})
+	if err != nil {
+		return err
+	}
+	return e.SendByte(buff)
+}
+
+func (e *WebSocketAgent) marshal(cmd, seq uint32, data interface{}) ([]byte, error) {
+	return e.RouteHandle.MarshalFrame(e.frameFormat(), cmd, seq, data)
+}
+
+// 当前连接协商的消息格式
+func (e *WebSocketAgent) frameFormat() FrameFormat {
+	return FrameFormat{Codec: e.Codec(), HeaderCmd: e.headerCmd, Compress: e.compress}
+}
+
+// Codec 当前连接使用的编码方式
+func (e *WebSocketAgent) Codec() ICodec {
+	if e.codec == nil {
+		return e.RouteHandle.Codec()
+	}
+	return e.codec
+}
+
+// SendByte 放入发送队列, 由写协程加密并发送
 func (e *WebSocketAgent) SendByte(buff []byte) error {
+	if e.Encrypted() {
+		if size := uint32(len(buff) + SESSION_OVERHEAD); size > FRAME_MAX_SIZE {
+			return &ErrFrameTooLarge{Size: size, MaxSize: FRAME_MAX_SIZE}
+		}
+	}
+	if e.writer != nil {
+		return e.enqueue(writeItem{buff: buff})
+	}
+	e.sendLock.Lock()
+	defer e.sendLock.Unlock()
+	if e.cipher != nil {
+		var err error
+		if buff, err = e.cipher.Seal(buff); err != nil {
+			return err
+		}
+	}
+	return e.send(buff)
+}
+
+// 没有发送队列时直接写入连接
+func (e *WebSocketAgent) send(buff []byte) error {
+	if e.WriteTimeout > 0 {
+		e.Conn.SetWriteDeadline(time.Now().Add(e.WriteTimeout))
+	}
 	return websocket.Message.Send(e.Conn, buff)
 }
+
+// 处理客户端的握手消息, 回应服务器签名后的公钥, 之后收发的消息都要加密
+func (e *WebSocketAgent) handshake(peer []byte, signKey ed25519.PrivateKey) error {
+	if e.Encrypted() {
+		return ErrHandshakeTwice
+	}
+	key, err := NewHandshakeKey()
+	if err != nil {
+		return err
+	}
+	body, cipher, err := key.ServerSession(peer, signKey)
+	if err != nil {
+		return err
+	}
+	reply, err := (&Frame{Flags: FRAME_FLAG_HANDSHAKE, Body: body}).Encode()
+	if err != nil {
+		return err
+	}
+	if e.writer != nil {
+		// 写协程写出回应后开始加密, 之后放入队列的消息都会加密
+		// 放入队列时可能等待, 不能持有sendLock
+		if err := e.enqueue(writeItem{buff: reply, cipher: cipher}); err != nil {
+			return err
+		}
+		e.sendLock.Lock()
+		e.cipher = cipher
+		e.sendLock.Unlock()
+		return nil
+	}
+	e.sendLock.Lock()
+	defer e.sendLock.Unlock()
+	if err := e.send(reply); err != nil {
+		return err
+	}
+	e.cipher = cipher
+	return nil
+}
+
+// Encrypted 是否已经握手, 消息都是加密的
+func (e *WebSocketAgent) Encrypted() bool {
+	e.sendLock.Lock()
+	defer e.sendLock.Unlock()
+	return e.cipher != nil
+}
+
+// Close 按指定的原因立刻断开连接
+func (e *WebSocketAgent) Close(reason DisconnectReason) error {
+	e.setReason(reason)
+	return e.Conn.Close()
+}
+
+// CloseAfterWrite 发送完队列中的消息后断开连接, 队列已满时立刻断开
+func (e *WebSocketAgent) CloseAfterWrite(reason DisconnectReason) {
+	e.setReason(reason)
+	if e.writer == nil {
+		e.Conn.Close()
+		return
+	}
+	select {
+	case e.writer.queue <- writeItem{close: true}:
+	default:
+		e.Conn.Close()
+	}
+}
+
+// Reason 断开的原因, 连接未断开时为0
+func (e *WebSocketAgent) Reason() DisconnectReason {
+	return DisconnectReason(atomic.LoadInt32(&e.reason))
+}
+
+func (e *WebSocketAgent) setReason(reason DisconnectReason) {
+	atomic.CompareAndSwapInt32(&e.reason, 0, int32(reason))
+}
+
+// 发送ping帧, 客户端会自动回复pong
+// 有发送队列时由写协程发送, 不与写协程同时写入连接
+func (e *WebSocketAgent) ping() error {
+	if e.writer != nil {
+		return e.enqueue(writeItem{ping: true})
+	}
+	e.sendLock.Lock()
+	defer e.sendLock.Unlock()
+	if e.WriteTimeout > 0 {
+		e.Conn.SetWriteDeadline(time.Now().Add(e.WriteTimeout))
+	}
+	return pingCodec.Send(e.Conn, nil)
+}
+
+var pingCodec = websocket.Codec{
+	Marshal: func(v interface{}) ([]byte, byte, error) {
+		return nil, websocket.PingFrame, nil
+	},
+}
@@ -0,0 +1,173 @@
+package Network
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var ErrHandshakeReply = errors.New("没有收到握手的回应")
+
+// WebSocketClient 纯Go实现的客户端, 用于测试和机器人
+// 连接时协商编码方式, 之后收发的消息都在消息头中带cmd
+type WebSocketClient struct {
+	Conn    *websocket.Conn
+	codec   ICodec
+	cipher  *SessionCipher
+	maxSize uint32
+	seq     uint32   // 最后发送的请求序号
+	pending [][]byte // 握手时先收到的消息
+}
+
+// DialWebSocket 连接服务器, codec为nil时使用json
+func DialWebSocket(addr string, codec ICodec) (*WebSocketClient, error) {
+	if codec == nil {
+		codec = JsonCodec
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set("codec", codec.Name())
+	query.Set("compress", "1")
+	u.RawQuery = query.Encode()
+	origin := "http://" + u.Host + "/"
+	conn, err := websocket.Dial(u.String(), "", origin)
+	if err != nil {
+		return nil, err
+	}
+	conn.PayloadType = websocket.BinaryFrame
+	return &WebSocketClient{
+		Conn:    conn,
+		codec:   codec,
+		maxSize: FRAME_MAX_SIZE,
+	}, nil
+}
+
+// Handshake 与服务器协商密钥, 成功后收发的消息都加密
+// serverKey为服务器签名私钥对应的公钥, 需要预先保存在客户端中, 用来防止中间人
+func (e *WebSocketClient) Handshake(serverKey ed25519.PublicKey) error {
+	key, err := NewHandshakeKey()
+	if err != nil {
+		return err
+	}
+	buff, err := (&Frame{Flags: FRAME_FLAG_HANDSHAKE, Body: key.Public()}).Encode()
+	if err != nil {
+		return err
+	}
+	if err := websocket.Message.Send(e.Conn, buff); err != nil {
+		return err
+	}
+	for {
+		var reply []byte
+		if err := websocket.Message.Receive(e.Conn, &reply); err != nil {
+			return err
+		}
+		frame, err := ParseFrame(reply, e.maxSize)
+		if err != nil {
+			return err
+		}
+		if frame.Flags == FRAME_FLAG_ENCRYPT {
+			return ErrHandshakeReply
+		}
+		if frame.Flags != FRAME_FLAG_HANDSHAKE {
+			e.pending = append(e.pending, reply)
+			continue
+		}
+		e.cipher, err = key.ClientSession(frame.Body, serverKey)
+		return err
+	}
+}
+
+// Send 编码后发送, 握手后自动加密
+func (e *WebSocketClient) Send(cmd uint32, data interface{}) error {
+	body, err := e.codec.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return e.SendFrame(&Frame{Flags: FRAME_FLAG_CMD, Cmd: cmd, Body: body})
+}
+
+// Request 带序号发送, 返回本次请求的序号, 回应中带同样的序号
+func (e *WebSocketClient) Request(cmd uint32, data interface{}) (uint32, error) {
+	body, err := e.codec.Marshal(data)
+	if err != nil {
+		return 0, err
+	}
+	e.seq++
+	return e.seq, e.SendFrame(&Frame{Flags: FRAME_FLAG_CMD | FRAME_FLAG_SEQ, Cmd: cmd, Seq: e.seq, Body: body})
+}
+
+// SendFrame 发送一条消息, 握手后自动加密
+func (e *WebSocketClient) SendFrame(frame *Frame) error {
+	buff, err := frame.Encode()
+	if err != nil {
+		return err
+	}
+	if e.cipher != nil {
+		if buff, err = e.cipher.Seal(buff); err != nil {
+			return err
+		}
+	}
+	return websocket.Message.Send(e.Conn, buff)
+}
+
+// Receive 接收一条消息, 握手后只接受加密的消息
+func (e *WebSocketClient) Receive() (*Frame, error) {
+	var buff []byte
+	if len(e.pending) > 0 {
+		buff, e.pending = e.pending[0], e.pending[1:]
+		return ParseFrame(buff, e.maxSize)
+	}
+	if err := websocket.Message.Receive(e.Conn, &buff); err != nil {
+		return nil, err
+	}
+	if e.cipher == nil {
+		return ParseFrame(buff, e.maxSize)
+	}
+	frame, err := ParseFrame(buff, e.maxSize)
+	if err != nil {
+		return nil, err
+	}
+	if frame.Flags != FRAME_FLAG_ENCRYPT {
+		e.Conn.Close()
+		return nil, ErrDecryptFailed
+	}
+	if frame, err = e.cipher.openFrame(buff, e.maxSize); err != nil {
+		// 校验失败或计数不递增时不能再信任这个连接
+		e.Conn.Close()
+		return nil, err
+	}
+	return frame, nil
+}
+
+// ReceiveTo 接收一条消息并解码到v, 返回消息的cmd
+func (e *WebSocketClient) ReceiveTo(v interface{}) (uint32, error) {
+	frame, err := e.Receive()
+	if err != nil {
+		return 0, err
+	}
+	return frame.Cmd, e.codec.Unmarshal(frame.Body, v)
+}
+
+// SetReadTimeout 设置接收的超时, 为0时不限制
+func (e *WebSocketClient) SetReadTimeout(v time.Duration) {
+	if v > 0 {
+		e.Conn.SetReadDeadline(time.Now().Add(v))
+	} else {
+		e.Conn.SetReadDeadline(time.Time{})
+	}
+}
+
+// Encrypted 是否已经握手
+func (e *WebSocketClient) Encrypted() bool {
+	return e.cipher != nil
+}
+
+func (e *WebSocketClient) Close() error {
+	return e.Conn.Close()
+}
@@ -0,0 +1,347 @@
+package Network
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/team-zf/framework/messages"
+	"github.com/team-zf/framework/modules"
+	"golang.org/x/net/websocket"
+)
+
+type clientTestRoute struct {
+	WebSocketRoute
+	Name string `json:"name"`
+}
+
+func (e *clientTestRoute) Parse() {}
+
+// 处理过的请求数量
+var clientTestCount int64
+
+func (e *clientTestRoute) Handle(agent *WebSocketAgent) uint32 {
+	atomic.AddInt64(&clientTestCount, 1)
+	e.Data("echo", e.Name)
+	return 0
+}
+
+type clientTestResponse struct {
+	Cmd  uint32            `json:"cmd"`
+	Seq  uint32            `json:"seq"`
+	Code uint32            `json:"code"`
+	Data map[string]string `json:"data"`
+}
+
+// 启动测试用的服务器, 返回连接地址和断开原因
+func startTestServer(t *testing.T, opts ...modules.ModOptions) (*WebSocketModule, string, chan DisconnectReason, func()) {
+	handle := NewWebSocketRouteHandle()
+	handle.SetRoute(7, &clientTestRoute{})
+	reasons := make(chan DisconnectReason, 4)
+	opts = append([]modules.ModOptions{
+		WebSocketSetRoute(handle),
+		WebSocketSetOnDisconnect(func(agent *WebSocketAgent, reason DisconnectReason) {
+			reasons <- reason
+		}),
+	}, opts...)
+	mod := NewWebSocketModule(opts...)
+	if err := mod.Init(); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(mod.httpServer.Handler)
+	return mod, "ws" + strings.TrimPrefix(server.URL, "http") + "/", reasons, server.Close
+}
+
+// 测试用的签名私钥, 客户端用testSignKey.Public()校验服务器
+var testSignKey, _ = ParseSignKey("dGVhbS16ZiB0ZXN0IHNpZ24ga2V5IHNlZWQgMzJiISE=")
+
+// 启动要求握手的服务器
+func startEncryptServer(t *testing.T) (string, chan DisconnectReason, func()) {
+	_, addr, reasons, stop := startTestServer(t, WebSocketSetEncrypt(true), WebSocketSetSignKey(testSignKey), WebSocketSetCompress(64))
+	return addr, reasons, stop
+}
+
+func waitReason(t *testing.T, reasons chan DisconnectReason) DisconnectReason {
+	select {
+	case reason := <-reasons:
+		return reason
+	case <-time.After(3 * time.Second):
+		t.Fatal("连接没有断开")
+	}
+	return 0
+}
+
+func TestWebSocketClientEncrypt(t *testing.T) {
+	addr, reasons, stop := startEncryptServer(t)
+	defer stop()
+
+	for _, codec := range []ICodec{JsonCodec, MsgPackCodec} {
+		client, err := DialWebSocket(addr, codec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.SetReadTimeout(3 * time.Second)
+		if err := client.Handshake(testSignKey.Public().(ed25519.PublicKey)); err != nil {
+			t.Fatal(err)
+		}
+		// 超过压缩的长度时, 先压缩再加密
+		name := strings.Repeat(codec.Name(), 50)
+		if err := client.Send(7, map[string]interface{}{"name": name}); err != nil {
+			t.Fatal(err)
+		}
+		resp := &clientTestResponse{}
+		cmd, err := client.ReceiveTo(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cmd != 7 || resp.Code != 0 || resp.Data["echo"] != name {
+			t.Fatalf("%s: 回应不正确: %d %+v", codec.Name(), cmd, resp)
+		}
+		client.Close()
+		if reason := waitReason(t, reasons); reason != DisconnectEOF {
+			t.Fatalf("断开的原因不正确: %s", reason)
+		}
+	}
+}
+
+func TestWebSocketClientTampered(t *testing.T) {
+	addr, reasons, stop := startEncryptServer(t)
+	defer stop()
+
+	// 没有握手
+	client, err := DialWebSocket(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Send(7, map[string]interface{}{"name": "a"})
+	if reason := waitReason(t, reasons); reason != DisconnectProtocolError {
+		t.Fatalf("没有握手时断开的原因不正确: %s", reason)
+	}
+	client.Close()
+
+	// 修改加密后的消息
+	client, err = DialWebSocket(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.Handshake(testSignKey.Public().(ed25519.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+	inner, _ := (&Frame{Flags: FRAME_FLAG_CMD, Cmd: 7, Body: []byte(`{"name":"a"}`)}).Encode()
+	buff, err := client.cipher.Seal(inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buff[len(buff)-1] ^= 1
+	websocket.Message.Send(client.Conn, buff)
+	if reason := waitReason(t, reasons); reason != DisconnectProtocolError {
+		t.Fatalf("校验失败时断开的原因不正确: %s", reason)
+	}
+}
+
+// 客户端保存的公钥与服务器不一致时握手失败
+func TestWebSocketClientWrongServerKey(t *testing.T) {
+	addr, _, stop := startEncryptServer(t)
+	defer stop()
+	client, err := DialWebSocket(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	other, _ := ParseSignKey("YW5vdGhlciBrZXkgZm9yIHRoZSBtaXRtIHRlc3QgMzI=")
+	if err := client.Handshake(other.Public().(ed25519.PublicKey)); err != ErrHandshakeSign {
+		t.Fatalf("公钥不一致时应该返回ErrHandshakeSign: %v", err)
+	}
+}
+
+// 重放和反射服务器发出的消息都会断开连接
+func TestWebSocketClientReplay(t *testing.T) {
+	addr, reasons, stop := startEncryptServer(t)
+	defer stop()
+	inner, _ := (&Frame{Flags: FRAME_FLAG_CMD, Cmd: 7, Body: []byte(`{"name":"a"}`)}).Encode()
+
+	client, err := DialWebSocket(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetReadTimeout(3 * time.Second)
+	if err := client.Handshake(testSignKey.Public().(ed25519.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+	buff, err := client.cipher.Seal(inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	websocket.Message.Send(client.Conn, buff)
+	receiveResponse(t, client)
+	websocket.Message.Send(client.Conn, buff)
+	if reason := waitReason(t, reasons); reason != DisconnectProtocolError {
+		t.Fatalf("重放时断开的原因不正确: %s", reason)
+	}
+	client.Close()
+
+	client, err = DialWebSocket(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetReadTimeout(3 * time.Second)
+	if err := client.Handshake(testSignKey.Public().(ed25519.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+	client.Send(7, map[string]interface{}{"name": "a"})
+	var reply []byte
+	if err := websocket.Message.Receive(client.Conn, &reply); err != nil {
+		t.Fatal(err)
+	}
+	websocket.Message.Send(client.Conn, reply)
+	if reason := waitReason(t, reasons); reason != DisconnectProtocolError {
+		t.Fatalf("反射时断开的原因不正确: %s", reason)
+	}
+}
+
+// 接收一条消息, 返回消息头和解码后的回应
+func receiveResponse(t *testing.T, client *WebSocketClient) (*Frame, *clientTestResponse) {
+	frame, err := client.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &clientTestResponse{}
+	if err := client.codec.Unmarshal(frame.Body, resp); err != nil {
+		t.Fatal(err)
+	}
+	return frame, resp
+}
+
+func TestWebSocketClientSeq(t *testing.T) {
+	mod, addr, _, stop := startTestServer(t)
+	defer stop()
+	client, err := DialWebSocket(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetReadTimeout(3 * time.Second)
+
+	before := atomic.LoadInt64(&clientTestCount)
+	seq, err := client.Request(7, map[string]interface{}{"name": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, resp := receiveResponse(t, client)
+	if frame.Seq != seq || resp.Seq != seq || resp.Data["echo"] != "a" {
+		t.Fatalf("回应的序号不正确: %d %+v", frame.Seq, resp)
+	}
+
+	// 重发已经回应过的请求, 返回缓存的回应, 不再处理
+	body, _ := client.codec.Marshal(map[string]interface{}{"name": "b"})
+	client.SendFrame(&Frame{Flags: FRAME_FLAG_CMD | FRAME_FLAG_SEQ, Cmd: 7, Seq: seq, Body: body})
+	if frame, resp = receiveResponse(t, client); frame.Seq != seq || resp.Data["echo"] != "a" {
+		t.Fatalf("重发的回应不正确: %d %+v", frame.Seq, resp)
+	}
+	if count := atomic.LoadInt64(&clientTestCount) - before; count != 1 {
+		t.Fatalf("重发的请求被处理了%d次", count)
+	}
+
+	// 跳过的序号是乱序
+	client.SendFrame(&Frame{Flags: FRAME_FLAG_CMD | FRAME_FLAG_SEQ, Cmd: 7, Seq: seq + 2, Body: body})
+	if frame, resp = receiveResponse(t, client); frame.Seq != seq+2 || resp.Code != messages.RC_Seq_Error {
+		t.Fatalf("乱序的回应不正确: %d %+v", frame.Seq, resp)
+	}
+
+	// 消息头中不带cmd时, 序号在消息体中
+	body, _ = client.codec.Marshal(map[string]interface{}{"cmd": 7, "seq": seq + 1, "name": "c"})
+	client.SendFrame(&Frame{Body: body})
+	if frame, resp = receiveResponse(t, client); frame.Seq != seq+1 || resp.Seq != seq+1 || resp.Data["echo"] != "c" {
+		t.Fatalf("消息体中带序号的回应不正确: %d %+v", frame.Seq, resp)
+	}
+
+	// 推送使用保留的序号
+	mod.Sessions().Broadcast(9, nil)
+	if frame, _ = receiveResponse(t, client); !IsPushCmd(frame.Cmd) || !IsPushSeq(frame.Seq) {
+		t.Fatalf("推送的序号不正确: %d %d", frame.Cmd, frame.Seq)
+	}
+}
+
+// 排空时空闲的连接和正在发送半条消息的连接都要立刻断开, 不等空闲超时
+func TestWebSocketDrainIdle(t *testing.T) {
+	mod, addr, reasons, stop := startTestServer(t)
+	defer stop()
+	idle, err := DialWebSocket(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+	partial, err := DialWebSocket(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer partial.Close()
+	// 一直在发送半条消息的连接
+	buff, _ := (&Frame{Flags: FRAME_FLAG_CMD, Cmd: 7, Body: make([]byte, 60000)}).Encode()
+	go func() {
+		for i := 0; i < len(buff); i++ {
+			if websocket.Message.Send(partial.Conn, buff[i:i+1]) != nil {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := mod.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if reason := waitReason(t, reasons); reason != DisconnectShutdown {
+			t.Fatalf("排空时断开的原因不正确: %s", reason)
+		}
+	}
+}
+
+func TestWebSocketClientKick(t *testing.T) {
+	mod, addr, reasons, stop := startTestServer(t)
+	defer stop()
+	client, err := DialWebSocket(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetReadTimeout(3 * time.Second)
+	// 先完成一次请求, 确保连接已经加入
+	client.Send(7, map[string]interface{}{"name": "a"})
+	receiveResponse(t, client)
+
+	var agent *WebSocketAgent
+	mod.Sessions().Range(func(v *WebSocketAgent) bool {
+		agent = v
+		return false
+	})
+	if agent == nil || !mod.Sessions().Kick(agent.ID()) {
+		t.Fatal("没有找到连接")
+	}
+	// 被踢的通知是推送, 不会与请求的回应混淆
+	frame, err := client.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &struct {
+		Data struct {
+			Code uint32 `json:"code"`
+		} `json:"data"`
+	}{}
+	client.codec.Unmarshal(frame.Body, msg)
+	if frame.Cmd != PushCmd(PUSH_CMD_KICK) || !IsPushSeq(frame.Seq) || msg.Data.Code != messages.RC_Kicked {
+		t.Fatalf("被踢的通知不正确: %d %d %+v", frame.Cmd, frame.Seq, msg)
+	}
+	if reason := waitReason(t, reasons); reason != DisconnectKicked {
+		t.Fatalf("断开的原因不正确: %s", reason)
+	}
+}
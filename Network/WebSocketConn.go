@@ -0,0 +1,92 @@
+package Network
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// DisconnectReason 连接断开的原因
+type DisconnectReason int32
+
+const (
+	DisconnectEOF            DisconnectReason = iota + 1 // 客户端主动断开
+	DisconnectIdleTimeout                                // 超时没有收到消息
+	DisconnectPingTimeout                                // 没有回应ping
+	DisconnectProtocolError                              // 消息格式错误
+	DisconnectKicked                                     // 被踢下线
+	DisconnectShutdown                                   // 服务器关闭
+	DisconnectError                                      // 读写出错
+	DisconnectDuplicateLogin                             // 帐号在其它地方登录
+	DisconnectSlowConsumer                               // 发送队列已满
+	DisconnectRateLimited                                // 多次超过请求频率限制
+)
+
+func (e DisconnectReason) String() string {
+	switch e {
+	case DisconnectEOF:
+		return "eof"
+	case DisconnectIdleTimeout:
+		return "idle_timeout"
+	case DisconnectPingTimeout:
+		return "ping_timeout"
+	case DisconnectProtocolError:
+		return "protocol_error"
+	case DisconnectKicked:
+		return "kicked"
+	case DisconnectShutdown:
+		return "shutdown"
+	case DisconnectError:
+		return "error"
+	case DisconnectDuplicateLogin:
+		return "duplicate_login"
+	case DisconnectSlowConsumer:
+		return "slow_consumer"
+	case DisconnectRateLimited:
+		return "rate_limited"
+	}
+	return "unknown"
+}
+
+type trackedConnKey struct{}
+
+// 记录最后一次从网络读到数据的时间, 包括ping/pong等控制帧
+type trackedConn struct {
+	net.Conn
+	lastRead int64
+}
+
+func (e *trackedConn) Read(b []byte) (int, error) {
+	n, err := e.Conn.Read(b)
+	if n > 0 {
+		atomic.StoreInt64(&e.lastRead, time.Now().UnixNano())
+	}
+	return n, err
+}
+
+func (e *trackedConn) LastRead() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&e.lastRead))
+}
+
+type trackedListener struct {
+	net.Listener
+}
+
+func (e *trackedListener) Accept() (net.Conn, error) {
+	conn, err := e.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &trackedConn{Conn: conn, lastRead: time.Now().UnixNano()}, nil
+}
+
+// 把连接放进请求的context, 升级为websocket后可以取出
+func trackedConnContext(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, trackedConnKey{}, conn)
+}
+
+func getTrackedConn(ctx context.Context) *trackedConn {
+	conn, _ := ctx.Value(trackedConnKey{}).(*trackedConn)
+	return conn
+}
@@ -1,10 +1,10 @@
 package Network
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/ed25519"
 	"fmt"
+	"github.com/team-zf/framework/config"
 	"github.com/team-zf/framework/logger"
 	"github.com/team-zf/framework/messages"
 	"github.com/team-zf/framework/modules"
@@ -12,33 +12,68 @@ import (
 	"github.com/team-zf/framework/utils/threads"
 	"golang.org/x/net/websocket"
 	"io"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
 const (
-	WRITE_TIMEOUT     time.Duration = time.Minute
-	HEARTBEAT_TIMEOUT time.Duration = time.Minute
+	WRITE_TIMEOUT     time.Duration = time.Minute      // 默认的写超时
+	HEARTBEAT_TIMEOUT time.Duration = time.Minute      // 默认多久没有收到消息断开连接
+	PONG_TIMEOUT      time.Duration = 10 * time.Second // 默认发送ping后等待回应的时间
+	SEND_QUEUE_SIZE   int           = 64               // 默认每个连接的发送队列长度
 )
 
 type WebSocketModule struct {
 	name         string
+	depends      []string
 	addr         string
+	writeTimeout time.Duration // 写超时
+	idleTimeout  time.Duration // 多久没有收到消息断开连接
+	pingInterval time.Duration // 发送ping的间隔, 为0时不发送
+	pongTimeout  time.Duration // 发送ping后多久没有收到数据断开连接
+	onDisconnect func(agent *WebSocketAgent, reason DisconnectReason)
+	sessions     *SessionManager
+	rooms        *RoomManager
+	maxMsgSize   uint32             // 单条消息的最大长度
+	codec        ICodec             // 默认的编码方式, 为nil时使用routeHandle的默认编码
+	compress     int                // 消息体达到这个长度时压缩, 为0时不压缩
+	encrypt      bool               // 是否要求客户端先握手, 之后的消息都加密
+	signKey      ed25519.PrivateKey // 握手时签名的私钥, 为nil时不能握手
+	limiter      *RateLimiter       // 请求频率限制, 为nil时不限制
+	middlewares  *Middlewares       // 包装路由执行的中间件
+	queueSize    int                // 每个连接的发送队列长度
+	overflow     OverflowPolicy     // 发送队列满时的处理方式
+	blockTimeout time.Duration      // OverflowBlock时最长等待的时间
+	droppedCount int64              // 因发送队列满丢弃的消息总数
 	httpServer   *http.Server
+	fault        chan error
 	routeHandle  *WebSocketRouteHandle
 	thgo         *threads.ThreadGo
-	requestCount int64 // 收到的请求总数
-	runingCount  int64 // 正在运行的总数
-	onlineCount  int64 // 在线总人数
+	agentLock    sync.Mutex
+	agents       map[*WebSocketAgent]bool // 当前所有的连接
+	agentWg      sync.WaitGroup           // 等待所有连接处理完成
+	draining     int32                    // 是否正在排空连接
+	maintenance  int32                    // 是否处于维护模式
+	requestCount int64                    // 收到的请求总数
+	runingCount  int64                    // 正在运行的总数
+	onlineCount  int64                    // 在线总人数
 }
 
-func (e *WebSocketModule) Init() {
+func (e *WebSocketModule) Init() error {
+	if e.encrypt && e.signKey == nil {
+		return fmt.Errorf("%s要求加密, %v", e.name, ErrNoSignKey)
+	}
+	e.fault = make(chan error, 1)
+	e.agents = make(map[*WebSocketAgent]bool)
 	e.httpServer = &http.Server{
-		Addr:         e.addr,
-		WriteTimeout: WRITE_TIMEOUT,
+		Addr:        e.addr,
+		ConnContext: trackedConnContext,
 	}
 	handler := websocket.Handler(func(conn *websocket.Conn) {
 		atomic.AddInt64(&e.onlineCount, 1)
@@ -47,33 +82,176 @@ func (e *WebSocketModule) Init() {
 		atomic.AddInt64(&e.onlineCount, -1)
 	})
 	mux := http.NewServeMux()
-	mux.Handle("/", handler)
+	mux.HandleFunc("/", func(res http.ResponseWriter, req *http.Request) {
+		// 被封禁的IP在升级成WebSocket之前拒绝
+		if ip := e.limiter.ClientIP(req); e.limiter != nil && e.limiter.Banned(ip) {
+			logger.Warn("%s拒绝被封禁的IP: %s", e.name, ip)
+			res.WriteHeader(http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(res, req)
+	})
 	e.httpServer.Handler = mux
+	return nil
 }
 
-func (e *WebSocketModule) Start() {
+func (e *WebSocketModule) Start() error {
+	// 先同步监听端口, 这样端口被占用等错误可以直接返回
+	ln, err := net.Listen("tcp", e.addr)
+	if err != nil {
+		return err
+	}
 	e.thgo.Go(func(ctx context.Context) {
 		logger.Notice("%s启动", e.name)
-		err := e.httpServer.ListenAndServe()
+		err := e.httpServer.Serve(&trackedListener{ln})
 		if err != nil && err != http.ErrServerClosed {
-			logger.Error("%s异常关闭, 原因: %+v", err)
+			logger.Error("%s异常关闭, 原因: %+v", e.name, err)
+			e.fault <- err
 		}
 	})
+	return nil
 }
 
-func (e *WebSocketModule) Stop() {
-	e.httpServer.Close()
+// Drain 停止接受新连接, 等每个连接处理完当前的请求后发送关闭帧断开
+func (e *WebSocketModule) Drain(ctx context.Context) error {
+	atomic.StoreInt32(&e.draining, 1)
+	if err := e.httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
+	// 让等待读取的连接立刻返回, 正在处理的请求不受影响
+	e.agentLock.Lock()
+	for agent := range e.agents {
+		agent.setReason(DisconnectShutdown)
+		agent.Conn.SetReadDeadline(time.Now())
+	}
+	e.agentLock.Unlock()
+	return threads.WaitContext(ctx, &e.agentWg)
+}
+
+func (e *WebSocketModule) Stop() error {
+	err := e.httpServer.Close()
+	// 排空超时后剩下的连接直接关闭
+	e.agentLock.Lock()
+	for agent := range e.agents {
+		agent.Close(DisconnectShutdown)
+	}
+	e.agentLock.Unlock()
 	e.thgo.CloseWait()
 	logger.Notice("%s已停止", e.name)
+	return err
+}
+
+func (e *WebSocketModule) Fault() <-chan error {
+	return e.fault
+}
+
+func (e *WebSocketModule) Name() string {
+	return e.name
+}
+
+func (e *WebSocketModule) DependsOn() []string {
+	return e.depends
+}
+
+func (e *WebSocketModule) SetMaintenance(v bool) {
+	if v {
+		atomic.StoreInt32(&e.maintenance, 1)
+	} else {
+		atomic.StoreInt32(&e.maintenance, 0)
+	}
+}
+
+// Kick 断开指定的连接, id可以是地址(ip:port), session:连接编号 或 user:用户编号
+func (e *WebSocketModule) Kick(id string) bool {
+	if strings.HasPrefix(id, "session:") {
+		sid, err := strconv.ParseUint(strings.TrimPrefix(id, "session:"), 10, 64)
+		return err == nil && e.kickAgent(func(agent *WebSocketAgent) bool { return agent.ID() == sid })
+	}
+	if strings.HasPrefix(id, "user:") {
+		uid, err := strconv.ParseInt(strings.TrimPrefix(id, "user:"), 10, 64)
+		return err == nil && uid != 0 && e.kickAgent(func(agent *WebSocketAgent) bool { return agent.UserID() == uid })
+	}
+	return e.kickAgent(func(agent *WebSocketAgent) bool { return agent.Conn.Request().RemoteAddr == id })
+}
+
+// 解析客户端的消息, 处理握手和解密, 握手消息返回nil
+// 客户端发送的压缩消息在这里解压, 解压后同样不能超过maxMsgSize
+func (e *WebSocketModule) readFrame(agent *WebSocketAgent, buff []byte) (*Frame, error) {
+	frame, err := ParseFrame(buff, e.maxMsgSize)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case frame.Flags == FRAME_FLAG_HANDSHAKE:
+		return nil, agent.handshake(frame.Body, e.signKey)
+	case frame.Flags == FRAME_FLAG_ENCRYPT:
+		if agent.cipher == nil {
+			return nil, ErrNotHandshake
+		}
+		return agent.cipher.openFrame(buff, e.maxMsgSize)
+	case agent.cipher != nil:
+		// 握手后不接受未加密的消息
+		return nil, ErrDecryptFailed
+	case e.encrypt:
+		return nil, ErrNotHandshake
+	}
+	return frame, nil
+}
+
+// 只踢本模块的连接, 共用SessionManager时不会误踢其它模块的连接
+func (e *WebSocketModule) kickAgent(match func(agent *WebSocketAgent) bool) bool {
+	e.agentLock.Lock()
+	var found *WebSocketAgent
+	for agent := range e.agents {
+		if match(agent) {
+			found = agent
+			break
+		}
+	}
+	e.agentLock.Unlock()
+	if found == nil {
+		return false
+	}
+	e.sessions.kick(found, DisconnectKicked)
+	return true
+}
+
+// 所有连接的发送队列中等待的消息总数
+func (e *WebSocketModule) queueDepth() int {
+	e.agentLock.Lock()
+	defer e.agentLock.Unlock()
+	total := 0
+	for agent := range e.agents {
+		total += agent.QueueLen()
+	}
+	return total
+}
+
+// Sessions 连接管理
+func (e *WebSocketModule) Sessions() *SessionManager {
+	return e.sessions
+}
+
+// Middlewares 中间件, 可以在启动前继续注册
+func (e *WebSocketModule) Middlewares() *Middlewares {
+	return e.middlewares
 }
 
-func (e *WebSocketModule) PrintStatus() string {
-	return fmt.Sprintf(
-		"\r\n\t\t%s的状态:\t%d/%d/%d\t(Online/Runing/Request)",
-		e.name,
-		atomic.LoadInt64(&e.onlineCount),
-		atomic.LoadInt64(&e.runingCount),
-		atomic.LoadInt64(&e.requestCount))
+// Rooms 房间管理
+func (e *WebSocketModule) Rooms() *RoomManager {
+	return e.rooms
+}
+
+func (e *WebSocketModule) Status() *modules.ModuleStatus {
+	status := modules.NewModuleStatus(e.name).
+		Gauge("onlineCount", float64(atomic.LoadInt64(&e.onlineCount))).
+		Gauge("userCount", float64(e.sessions.UserCount())).
+		Gauge("roomCount", float64(e.rooms.Count())).
+		Gauge("queueDepth", float64(e.queueDepth())).
+		Counter("droppedCount", atomic.LoadInt64(&e.droppedCount)).
+		Gauge("runingCount", float64(atomic.LoadInt64(&e.runingCount))).
+		Counter("requestCount", atomic.LoadInt64(&e.requestCount))
+	return e.limiter.addStatus(status)
 }
 
 func (e *WebSocketModule) Handle(conn *websocket.Conn) {
@@ -81,78 +259,265 @@ func (e *WebSocketModule) Handle(conn *websocket.Conn) {
 	defer e.thgo.Wg.Done()
 	defer conn.Close()
 
-	agent := new(WebSocketAgent)
-	agent.Conn = conn
-	agent.RouteHandle = e.routeHandle
+	metricConnects.Inc(e.name)
+	metricOnline.Inc(e.name)
+	defer metricOnline.Dec(e.name)
 
-	// 心跳检测机制
-	heartbeat := make(chan bool, 8)
-	e.thgo.Go(func(ctx context.Context) {
-		timeout := time.NewTimer(HEARTBEAT_TIMEOUT)
-		defer timeout.Stop()
-		defer conn.Close()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-timeout.C:
-				timeout.Reset(HEARTBEAT_TIMEOUT)
-				//return
-			case reset := <-heartbeat:
-				if reset {
-					timeout.Reset(HEARTBEAT_TIMEOUT)
-				} else {
-					return
-				}
-			}
+	ip := e.limiter.ClientIP(conn.Request())
+	agent := newWebSocketAgent(conn, e.routeHandle)
+	agent.WriteTimeout = e.writeTimeout
+	agent.codec = e.codec
+	// 客户端连接时可以用 ?codec=名称 选择编码方式, 之后的消息都在消息头中带cmd
+	if name := conn.Request().URL.Query().Get("codec"); name != "" {
+		if agent.codec = GetCodec(name); agent.codec == nil {
+			logger.Error("%s不支持的编码方式: %s, 连接: %s", e.name, name, conn.Request().RemoteAddr)
+			return
 		}
+		agent.headerCmd = true
+	}
+	// 客户端连接时用 ?compress=1 表示可以解压服务器发送的消息
+	if e.compress > 0 && conn.Request().URL.Query().Get("compress") == "1" {
+		agent.compress = e.compress
+	}
+	agent.writer = newWriteQueue(e.queueSize, e.overflow, e.blockTimeout)
+	agent.writer.onDrop = func() {
+		atomic.AddInt64(&e.droppedCount, 1)
+		metricDropped.Inc(e.name)
+	}
+	e.thgo.Go(func(ctx context.Context) {
+		agent.writeLoop()
 	})
+	// 等待已经放入队列的响应发送完再断开
+	defer agent.stopWriter(e.writeTimeout)
+
+	e.agentLock.Lock()
+	e.agents[agent] = true
+	e.agentWg.Add(1)
+	e.agentLock.Unlock()
+	e.sessions.add(agent)
+	defer func() {
+		// 先确定断开的原因, 之后不能再加入房间
+		agent.setReason(DisconnectError)
+		e.rooms.LeaveAll(agent)
+		e.sessions.remove(agent)
+		e.agentLock.Lock()
+		delete(e.agents, agent)
+		e.agentLock.Unlock()
+		e.agentWg.Done()
+
+		reason := agent.Reason()
+		metricDisconnects.Inc(e.name, reason.String())
+		logger.Notice("%s连接断开: %s, 原因: %s", e.name, conn.Request().RemoteAddr, reason)
+		if e.onDisconnect != nil {
+			threads.Try(func() {
+				e.onDisconnect(agent, reason)
+			}, func(err error) {
+				logger.Error("%s断开连接的回调出错: %+v", e.name, err)
+			})
+		}
+	}()
+	if atomic.LoadInt32(&e.draining) == 1 {
+		agent.setReason(DisconnectShutdown)
+		return
+	}
+
+	// 定时发送ping, 超时没有收到任何数据时断开
+	if e.pingInterval > 0 {
+		if tracked := getTrackedConn(conn.Request().Context()); tracked != nil {
+			closed := make(chan struct{})
+			defer close(closed)
+			e.thgo.Go(func(ctx context.Context) {
+				e.keepAlive(ctx, closed, agent, tracked)
+			})
+		}
+	}
 
 	// 消息接收
 	e.thgo.Try(func(ctx context.Context) {
-		buffer := &bytes.Buffer{}
+		decoder := NewFrameDecoder(e.maxMsgSize)
 		for {
-			view := make([]byte, 1024*10)
-			n, err := conn.Read(view)
-			if err != nil {
-				if err == io.EOF {
-					heartbeat <- false
-				}
-				break
+			// 排空时Drain会把读超时设为当前时间来唤醒读取, 这时不能再设置空闲超时
+			// 设置之后再检查一次, Drain在两次检查之间开始时也不会被覆盖
+			if e.idleTimeout > 0 && atomic.LoadInt32(&e.draining) == 0 {
+				conn.SetReadDeadline(time.Now().Add(e.idleTimeout))
 			}
-			buffer.Write(view[:n])
-			buff := buffer.Bytes()
-
-			msglen, ok := e.routeHandle.CheckMaxLenVaild(buff)
-			if ok { // 消息拼接完成
-				buff = buffer.Next(int(msglen))
-			} else if msglen > 0 { // 消息拼接未完成
-				continue
-			} else { // 异常消息的长度为0
-				break
+			if atomic.LoadInt32(&e.draining) == 1 {
+				agent.setReason(DisconnectShutdown)
+				return
 			}
-
-			data, err := e.routeHandle.Unmarshal(buff)
-			if err != nil {
-				logger.Error("%s消息解码失败, 原因: %+v", e.name, err)
+			if _, err := decoder.ReadOnce(conn); err != nil {
+				if err == io.EOF {
+					agent.setReason(DisconnectEOF)
+				} else if atomic.LoadInt32(&e.draining) == 1 {
+					agent.setReason(DisconnectShutdown)
+				} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					agent.setReason(DisconnectIdleTimeout)
+				} else {
+					agent.setReason(DisconnectError)
+				}
 				return
 			}
 
-			route := data.(IWebSocketRoute)
-			logger.Notice("%s收到请求: %s", e.name, route.Header())
+			// 一次读取可能包含多条消息
+			for {
+				buff, err := decoder.Next()
+				if err != nil {
+					logger.Error("%s消息格式错误: %s, 原因: %+v", e.name, conn.Request().RemoteAddr, err)
+					agent.setReason(DisconnectProtocolError)
+					return
+				}
+				if buff == nil {
+					break
+				}
 
-			heartbeat <- true
-			atomic.AddInt64(&e.requestCount, 1)
-			atomic.AddInt64(&e.runingCount, 1)
-			e.TryDirectCall(route, agent)
-			atomic.AddInt64(&e.runingCount, -1)
+				frame, err := e.readFrame(agent, buff)
+				if err != nil {
+					logger.Error("%s消息格式错误: %s, 原因: %+v", e.name, conn.Request().RemoteAddr, err)
+					agent.setReason(DisconnectProtocolError)
+					return
+				}
+				if frame == nil {
+					continue
+				}
+				route, err := e.routeHandle.DecodeFrame(agent.Codec(), frame)
+				if err != nil {
+					logger.Error("%s消息解码失败, 原因: %+v", e.name, err)
+					agent.setReason(DisconnectProtocolError)
+					return
+				}
+
+				logger.Notice("%s收到请求: %s", e.name, route.Header())
+
+				// 被限流的请求不处理, 也不占用序号, 客户端可以用同样的序号重试
+				if e.limiter != nil {
+					if ok, punish := e.limiter.Allow(ip, strconv.FormatUint(agent.ID(), 10), route); !ok {
+						observeLimited(e.name, route.GetCmd())
+						agent.replyCode(route.GetCmd(), frame.Seq, messages.RC_RateLimited)
+						if punish {
+							logger.Warn("%s请求过于频繁, 断开连接: %s", e.name, conn.Request().RemoteAddr)
+							agent.CloseAfterWrite(DisconnectRateLimited)
+							return
+						}
+						continue
+					}
+				}
+
+				// 带序号的请求, 乱序或重放的不处理, 重发的直接返回缓存的回应
+				if frame.Seq != 0 {
+					cached, ok := agent.seqs.check(frame.Seq)
+					if !ok {
+						logger.Warn("%s请求序号错误: %s, Cmd: %d, Seq: %d", e.name, conn.Request().RemoteAddr, route.GetCmd(), frame.Seq)
+						agent.replyCode(route.GetCmd(), frame.Seq, messages.RC_Seq_Error)
+						continue
+					}
+					if cached != nil {
+						agent.SendByte(cached)
+						continue
+					}
+				}
+				agent.seqs.current = frame.Seq
+
+				atomic.AddInt64(&e.requestCount, 1)
+				atomic.AddInt64(&e.runingCount, 1)
+				observeRequest(e.name, route.GetCmd())
+				e.TryDirectCall(route, agent)
+				atomic.AddInt64(&e.runingCount, -1)
+				agent.seqs.current = 0
+
+				// 正在关闭, 处理完当前请求后断开
+				if atomic.LoadInt32(&e.draining) == 1 {
+					agent.setReason(DisconnectShutdown)
+					return
+				}
+			}
 		}
 	}, func(err error) {
-		// 无需处理
+		agent.setReason(DisconnectError)
 	})
 }
 
+// 每隔pingInterval发送一次ping, pongTimeout内没有收到任何数据时断开连接
+func (e *WebSocketModule) keepAlive(ctx context.Context, closed chan struct{}, agent *WebSocketAgent, tracked *trackedConn) {
+	t := time.NewTimer(e.pingInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-closed:
+			return
+		case <-t.C:
+		}
+		sent := time.Now()
+		if err := agent.ping(); err != nil {
+			agent.Close(DisconnectError)
+			return
+		}
+		t.Reset(e.pongTimeout)
+		select {
+		case <-ctx.Done():
+			return
+		case <-closed:
+			return
+		case <-t.C:
+		}
+		if tracked.LastRead().Before(sent) {
+			agent.Close(DisconnectPingTimeout)
+			return
+		}
+		if wait := e.pingInterval - e.pongTimeout; wait > 0 {
+			t.Reset(wait)
+		} else {
+			t.Reset(0)
+		}
+	}
+}
+
 func (e *WebSocketModule) TryDirectCall(route IWebSocketRoute, agent *WebSocketAgent) {
+	ctx := newRouteContext(route)
+	ctx.Agent = agent
+	ctx.Request = agent.Conn.Request()
+	ctx.Code = messages.RC_Success
+	defer func() {
+		observeResponse(e.name, route.GetCmd(), ctx.Code, ctx.Begin)
+	}()
+
+	// 维护中, 不处理请求
+	if atomic.LoadInt32(&e.maintenance) == 1 {
+		ctx.Code = messages.RC_Maintenance
+		agent.reply(route.GetCmd(), &WebSocketResponse{
+			Cmd:  route.GetCmd(),
+			Seq:  agent.seqs.current,
+			Code: ctx.Code,
+		})
+		return
+	}
+
+	threads.Try(func() {
+		e.middlewares.run(ctx, func() {
+			e.callRoute(ctx, route, agent)
+		})
+	}, func(err error) {
+		logger.Error("%s中间件错误: %s, Error: %+v", e.name, route.Header(), err)
+		ctx.Code = messages.RC_LOGIC_ERROR
+		ctx.Result = nil
+	})
+
+	// 中间件可能修改了结果代码
+	if ctx.Result != nil {
+		ctx.Result["code"] = ctx.Code
+		agent.reply(route.GetCmd(), ctx.Result)
+	} else {
+		agent.reply(route.GetCmd(), &WebSocketResponse{
+			Cmd:  route.GetCmd(),
+			Seq:  agent.seqs.current,
+			Code: ctx.Code,
+		})
+	}
+}
+
+// 参数解析和逻辑运行, 结果写入ctx
+func (e *WebSocketModule) callRoute(ctx *RouteContext, route IWebSocketRoute, agent *WebSocketAgent) {
 	utils.QueueRun(
 		// 参数解析
 		func() bool {
@@ -161,11 +526,7 @@ func (e *WebSocketModule) TryDirectCall(route IWebSocketRoute, agent *WebSocketA
 				route.Parse()
 			}, func(err error) {
 				result = false
-				// 返回参数错误
-				agent.SendData(&WebSocketResponse{
-					Cmd:  route.GetCmd(),
-					Code: messages.RC_Param_Error,
-				})
+				ctx.Code = messages.RC_Param_Error
 			})
 			return result
 		},
@@ -173,30 +534,20 @@ func (e *WebSocketModule) TryDirectCall(route IWebSocketRoute, agent *WebSocketA
 		func() bool {
 			result := true
 			threads.Try(func() {
-				code := route.Handle(agent)
-				resp := &WebSocketResponse{
+				ctx.Code = route.Handle(agent)
+				ctx.Result = routeResult(&WebSocketResponse{
 					Cmd:  route.GetCmd(),
-					Code: code,
-				}
-				buff, _ := json.Marshal(resp)
-				jsmap := make(map[string]interface{})
-				json.Unmarshal(buff, &jsmap)
-				for k, v := range route.ToJsonMap() {
-					if _, ok := jsmap[k]; !ok {
-						jsmap[k] = v
-					}
-				}
-				agent.SendData(jsmap)
+					Seq:  agent.seqs.current,
+					Code: ctx.Code,
+				}, route)
 			}, func(err error) {
 				result = false
+				ctx.Code = messages.RC_LOGIC_ERROR
 				stacks := strings.Split(string(debug.Stack()), "\n")
-				stacks = append(stacks[9 : len(stacks)-(35)])
+				if len(stacks) > 9+35 {
+					stacks = stacks[9 : len(stacks)-35]
+				}
 				logger.Error("%s逻辑错误\nError: %s\nStack:\n%s\n", e.name, err.Error(), strings.Join(stacks, "\n"))
-				// 返回逻辑错误
-				agent.SendData(&WebSocketResponse{
-					Cmd:  route.GetCmd(),
-					Code: messages.RC_LOGIC_ERROR,
-				})
 			})
 			return result
 		},
@@ -205,9 +556,21 @@ func (e *WebSocketModule) TryDirectCall(route IWebSocketRoute, agent *WebSocketA
 
 func NewWebSocketModule(opts ...modules.ModOptions) *WebSocketModule {
 	result := &WebSocketModule{
-		name: "WebSocket",
-		addr: ":8081",
-		thgo: threads.NewThreadGo(),
+		name:         "WebSocket",
+		addr:         ":8081",
+		writeTimeout: WRITE_TIMEOUT,
+		idleTimeout:  HEARTBEAT_TIMEOUT,
+		pongTimeout:  PONG_TIMEOUT,
+		thgo:         threads.NewThreadGo(),
+		routeHandle:  NewWebSocketRouteHandle(),
+		sessions:     NewSessionManager(DuplicateKickOld),
+		rooms:        NewRoomManager(),
+		middlewares:  NewMiddlewares(),
+		maxMsgSize:   uint32(ROUTEHANDLE_MAXLEN),
+		compress:     COMPRESS_THRESHOLD,
+		queueSize:    SEND_QUEUE_SIZE,
+		overflow:     OverflowDrop,
+		blockTimeout: time.Second,
 	}
 
 	for _, opt := range opts {
@@ -222,6 +585,12 @@ func WebSocketSetName(v string) modules.ModOptions {
 	}
 }
 
+func WebSocketSetDependsOn(v ...string) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).depends = v
+	}
+}
+
 func WebSocketSetAddr(v string) modules.ModOptions {
 	return func(mod modules.IModule) {
 		mod.(*WebSocketModule).addr = v
@@ -233,3 +602,187 @@ func WebSocketSetRoute(v *WebSocketRouteHandle) modules.ModOptions {
 		mod.(*WebSocketModule).routeHandle = v
 	}
 }
+
+// 按配置文件中的WebSocket部分设置监听地址和超时
+func WebSocketSetConfig(v *config.WebSocketConfig) modules.ModOptions {
+	return func(mod modules.IModule) {
+		if v == nil {
+			return
+		}
+		e := mod.(*WebSocketModule)
+		e.addr = v.Addr
+		if v.WriteTimeout > 0 {
+			e.writeTimeout = time.Duration(v.WriteTimeout) * time.Second
+		}
+		if v.HeartbeatTimeout > 0 {
+			e.idleTimeout = time.Duration(v.HeartbeatTimeout) * time.Second
+		}
+		if v.PingInterval > 0 {
+			e.pingInterval = time.Duration(v.PingInterval) * time.Second
+		}
+		if v.PongTimeout > 0 {
+			e.pongTimeout = time.Duration(v.PongTimeout) * time.Second
+		}
+		if v.MaxMessageSize > 0 {
+			e.maxMsgSize = uint32(v.MaxMessageSize)
+		}
+		if v.Codec != "" {
+			if codec := GetCodec(v.Codec); codec != nil {
+				e.codec = codec
+			} else {
+				logger.Error("%s不支持的编码方式: %s, 使用默认的编码方式", e.name, v.Codec)
+			}
+		}
+		if v.CompressThreshold != 0 {
+			e.compress = v.CompressThreshold
+		}
+		if v.Encrypt {
+			e.encrypt = true
+		}
+		if v.SignKey != "" {
+			if key, err := ParseSignKey(v.SignKey); err == nil {
+				e.signKey = key
+			} else {
+				logger.Error("%s握手的签名私钥格式错误: %v", e.name, err)
+			}
+		}
+		if v.RateLimit != nil {
+			e.limiter = NewRateLimiter(v.RateLimit)
+		}
+		if v.SendQueueSize > 0 {
+			e.queueSize = v.SendQueueSize
+		}
+		if policy, err := ParseOverflowPolicy(v.Overflow); err == nil {
+			e.overflow = policy
+		}
+		if v.OverflowTimeout > 0 {
+			e.blockTimeout = time.Duration(v.OverflowTimeout) * time.Millisecond
+		}
+	}
+}
+
+// 设置写超时, 为0时不限制
+func WebSocketSetWriteTimeout(v time.Duration) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).writeTimeout = v
+	}
+}
+
+// 设置多久没有收到消息断开连接, 为0时不限制
+func WebSocketSetIdleTimeout(v time.Duration) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).idleTimeout = v
+	}
+}
+
+// 设置发送ping的间隔和等待回应的时间, interval为0时不发送
+func WebSocketSetPing(interval, timeout time.Duration) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).pingInterval = interval
+		if timeout > 0 {
+			mod.(*WebSocketModule).pongTimeout = timeout
+		}
+	}
+}
+
+// 设置连接断开时的回调
+func WebSocketSetOnDisconnect(fn func(agent *WebSocketAgent, reason DisconnectReason)) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).onDisconnect = fn
+	}
+}
+
+// 设置连接管理, 多个模块共用时用户的重复登录检查跨模块生效
+func WebSocketSetSessionManager(v *SessionManager) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).sessions = v
+	}
+}
+
+// 设置重复登录的处理方式
+func WebSocketSetDuplicateLogin(v DuplicateLoginPolicy) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).sessions.policy = v
+	}
+}
+
+// 设置房间管理, 多个模块可以共用
+func WebSocketSetRoomManager(v *RoomManager) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).rooms = v
+	}
+}
+
+// 设置每个连接的发送队列长度, 以及队列满时的处理方式, timeout只在OverflowBlock时有效
+func WebSocketSetSendQueue(size int, overflow OverflowPolicy, timeout time.Duration) modules.ModOptions {
+	return func(mod modules.IModule) {
+		e := mod.(*WebSocketModule)
+		if size > 0 {
+			e.queueSize = size
+		}
+		e.overflow = overflow
+		if timeout > 0 {
+			e.blockTimeout = timeout
+		}
+	}
+}
+
+// 设置单条消息的最大长度(包含消息头), 超过时断开连接
+func WebSocketSetMaxMessageSize(v uint32) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).maxMsgSize = v
+	}
+}
+
+// 设置默认的编码方式, 客户端可以在连接时用 ?codec=名称 另外选择
+func WebSocketSetCodec(v ICodec) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).codec = v
+	}
+}
+
+// 设置消息体达到多少字节时压缩, 只对连接时带 ?compress=1 的客户端生效, 小于等于0时不压缩
+func WebSocketSetCompress(threshold int) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).compress = threshold
+	}
+}
+
+// 设置是否要求客户端先握手, 为false时客户端也可以选择握手
+func WebSocketSetEncrypt(v bool) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).encrypt = v
+	}
+}
+
+// 设置握手时签名的私钥, 客户端用对应的公钥校验服务器, 没有设置时客户端不能握手
+func WebSocketSetSignKey(v ed25519.PrivateKey) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).signKey = v
+	}
+}
+
+// 设置请求频率限制, 为nil时不限制, 多个模块可以共用一个
+func WebSocketSetRateLimiter(v *RateLimiter) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).limiter = v
+	}
+}
+
+// 设置中间件, 可以和Http模块共用同一个
+func WebSocketSetMiddlewares(v *Middlewares) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*WebSocketModule).middlewares = v
+	}
+}
+
+// 注册对所有cmd生效的中间件
+func WebSocketUse(fns ...Middleware) modules.ModOptions {
+	return func(mod modules.IModule) {
+		e := mod.(*WebSocketModule)
+		if e.middlewares == nil {
+			e.middlewares = NewMiddlewares()
+		}
+		e.middlewares.Use(fns...)
+	}
+}
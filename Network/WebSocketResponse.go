@@ -2,5 +2,6 @@ package Network
 
 type WebSocketResponse struct {
 	Cmd  uint32 `json:"cmd"`
+	Seq  uint32 `json:"seq,omitempty"` // 请求带序号时原样返回
 	Code uint32 `json:"code"`
 }
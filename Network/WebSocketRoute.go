@@ -12,6 +12,10 @@ func (e *WebSocketRoute) GetCmd() uint32 {
 	return e.Cmd
 }
 
+func (e *WebSocketRoute) SetCmd(cmd uint32) {
+	e.Cmd = cmd
+}
+
 func (e *WebSocketRoute) Header() string {
 	return fmt.Sprintf("Cmd: %d, Params: %+v", e.Cmd, e.Params)
 }
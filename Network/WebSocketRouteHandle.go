@@ -3,7 +3,6 @@ package Network
 import (
 	"bytes"
 	"encoding/binary"
-	"encoding/json"
 	"fmt"
 	"github.com/team-zf/framework/utils"
 )
@@ -15,14 +14,39 @@ const (
 
 type WebSocketRouteHandle struct {
 	routes map[uint32]IWebSocketRoute
+	codec  ICodec // 默认的编码方式
 }
 
+// Marshal 用默认的编码方式按旧的格式编码, 消息头中不带cmd
 func (e *WebSocketRouteHandle) Marshal(data interface{}) ([]byte, error) {
-	buffer := &bytes.Buffer{}
-	buff, err := json.Marshal(data)
+	return e.MarshalFrame(FrameFormat{Codec: e.codec}, 0, 0, data)
+}
+
+// MarshalFrame 按客户端协商的格式编码, seq为0时不带序号
+// 消息头中不带cmd且不需要压缩时使用旧的格式, 序号只在消息体中
+func (e *WebSocketRouteHandle) MarshalFrame(format FrameFormat, cmd, seq uint32, data interface{}) ([]byte, error) {
+	codec := format.Codec
+	if codec == nil {
+		codec = e.codec
+	}
+	buff, err := codec.Marshal(data)
 	if err != nil {
 		return nil, err
 	}
+	frame := &Frame{Cmd: cmd, Seq: seq, Body: buff}
+	if format.HeaderCmd {
+		frame.Flags |= FRAME_FLAG_CMD
+		if seq != 0 {
+			frame.Flags |= FRAME_FLAG_SEQ
+		}
+	}
+	if format.Compress > 0 && len(buff) >= format.Compress {
+		frame.Flags |= FRAME_FLAG_COMPRESS
+	}
+	if frame.Flags != 0 {
+		return frame.Encode()
+	}
+	buffer := &bytes.Buffer{}
 	temp := make([]byte, 4)
 	msglen := uint32(len(buff)+8) | ROUTEHANDLE_HEADER
 	binary.LittleEndian.PutUint32(temp, msglen)
@@ -31,43 +55,79 @@ func (e *WebSocketRouteHandle) Marshal(data interface{}) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
+// Unmarshal 用默认的编码方式解码
 func (e *WebSocketRouteHandle) Unmarshal(buff []byte) (interface{}, error) {
-	msglen := binary.LittleEndian.Uint32(buff[:4]) ^ ROUTEHANDLE_HEADER
-	if msglen != uint32(len(buff)) {
-		return nil, fmt.Errorf("MsgLen Error: %d", msglen)
-	}
-	buff = buff[4:]
+	return e.UnmarshalFrame(e.codec, buff)
+}
 
-	// 从Body中取得JsonMap
-	jsmap := make(map[string]interface{})
-	err := json.Unmarshal(buff, &jsmap)
+// UnmarshalFrame 解码一条完整的消息, 压缩的消息解压后不能超过ROUTEHANDLE_MAXLEN
+func (e *WebSocketRouteHandle) UnmarshalFrame(codec ICodec, buff []byte) (IWebSocketRoute, error) {
+	frame, err := ParseFrame(buff, uint32(ROUTEHANDLE_MAXLEN))
 	if err != nil {
 		return nil, err
 	}
+	return e.DecodeFrame(codec, frame)
+}
 
-	// 从JsonMap中取得Cmd
-	cmd, err := utils.NewStringAny(jsmap["cmd"]).ToUint32()
-	if err != nil {
-		return nil, err
+// DecodeFrame 取得消息对应的路由, 消息头中带cmd时不需要从消息体中查找cmd
+// 消息头中不带cmd时, 从消息体中取得的cmd和seq会写回frame
+func (e *WebSocketRouteHandle) DecodeFrame(codec ICodec, frame *Frame) (IWebSocketRoute, error) {
+	cmd := frame.Cmd
+	if frame.Flags&FRAME_FLAG_CMD == 0 {
+		var seq uint32
+		var err error
+		if cmd, seq, err = decodeCmd(codec, frame.Body); err != nil {
+			return nil, err
+		}
+		frame.Cmd = cmd
+		if frame.Flags&FRAME_FLAG_SEQ == 0 {
+			frame.Seq = seq
+		}
 	}
 
 	route, err := e.GetRoute(cmd)
 	if err != nil {
 		return nil, err
 	}
-
-	json.Unmarshal(buff, route)
+	if len(frame.Body) > 0 {
+		if err := codec.Unmarshal(frame.Body, route); err != nil {
+			return nil, err
+		}
+	}
+	if setter, ok := route.(ICmdSetter); ok {
+		setter.SetCmd(cmd)
+	}
 	return route, nil
 }
 
+func (e *WebSocketRouteHandle) Codec() ICodec {
+	return e.codec
+}
+
+// SetCodec 设置默认的编码方式
+func (e *WebSocketRouteHandle) SetCodec(codec ICodec) {
+	e.codec = codec
+}
+
+// CheckMaxLenVaild 检查消息长度, 压缩的消息完整时检查解压后的长度
 func (e *WebSocketRouteHandle) CheckMaxLenVaild(buff []byte) (uint32, bool) {
-	msglen := binary.LittleEndian.Uint32(buff[:4]) ^ ROUTEHANDLE_HEADER
+	// 消息头不完整
+	if len(buff) < FRAME_HEADER_SIZE {
+		return FRAME_HEADER_SIZE, false
+	}
+	header := binary.LittleEndian.Uint32(buff[:4]) ^ ROUTEHANDLE_HEADER
+	msglen := header & FRAME_LEN_MASK
 	if msglen > uint32(ROUTEHANDLE_MAXLEN) {
 		return 0, false
 	}
 	if msglen > uint32(len(buff)) {
 		return msglen, false
 	}
+	if byte(header>>24)&FRAME_FLAG_COMPRESS != 0 {
+		if _, err := ParseFrame(buff[:msglen], uint32(ROUTEHANDLE_MAXLEN)); err != nil {
+			return 0, false
+		}
+	}
 	return msglen, true
 }
 
@@ -85,5 +145,8 @@ func (e *WebSocketRouteHandle) SetRoute(cmd uint32, route IWebSocketRoute) {
 }
 
 func NewWebSocketRouteHandle() *WebSocketRouteHandle {
-	return &WebSocketRouteHandle{routes: make(map[uint32]IWebSocketRoute)}
+	return &WebSocketRouteHandle{
+		routes: make(map[uint32]IWebSocketRoute),
+		codec:  JsonCodec,
+	}
 }
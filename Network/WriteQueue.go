@@ -0,0 +1,180 @@
+package Network
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/websocket"
+	"strings"
+	"time"
+)
+
+// OverflowPolicy 发送队列满时的处理方式
+type OverflowPolicy int
+
+const (
+	OverflowDrop       OverflowPolicy = iota // 丢弃这条消息
+	OverflowDisconnect                       // 断开处理不过来的连接
+	OverflowBlock                            // 等待队列空出位置, 超时后丢弃
+)
+
+var ErrQueueFull = errors.New("发送队列已满")
+
+// ParseOverflowPolicy 解析配置文件中的 drop, disconnect, block
+func ParseOverflowPolicy(v string) (OverflowPolicy, error) {
+	switch strings.ToLower(v) {
+	case "", "drop":
+		return OverflowDrop, nil
+	case "disconnect":
+		return OverflowDisconnect, nil
+	case "block":
+		return OverflowBlock, nil
+	}
+	return OverflowDrop, fmt.Errorf("未知的发送队列溢出策略: %s", v)
+}
+
+// 发送队列中的一项
+type writeItem struct {
+	buff   []byte
+	ping   bool           // 发送ping帧
+	close  bool           // 写完之前的消息后断开
+	cipher *SessionCipher // 写完这条消息后开始加密, 用于握手的回应
+}
+
+// 发送队列, 由单独的协程按顺序加密并写入连接
+// 加密在写协程中进行, 多个协程同时发送时加密的顺序与写入的顺序一致
+type writeQueue struct {
+	queue    chan writeItem
+	stop     chan struct{} // 关闭后写完队列中剩余的消息退出
+	done     chan struct{} // 写协程已退出
+	overflow OverflowPolicy
+	timeout  time.Duration // OverflowBlock时最长等待的时间
+	onDrop   func()
+}
+
+func newWriteQueue(size int, overflow OverflowPolicy, timeout time.Duration) *writeQueue {
+	return &writeQueue{
+		queue:    make(chan writeItem, size),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		overflow: overflow,
+		timeout:  timeout,
+	}
+}
+
+// 放入发送队列, 队列满时按策略处理
+// OverflowBlock时会等待, 调用时不能持有sendLock, 否则一个慢的连接会挡住所有发送者
+func (e *WebSocketAgent) enqueue(item writeItem) error {
+	q := e.writer
+	select {
+	case <-q.done:
+		return ErrSessionClosed
+	default:
+	}
+	select {
+	case q.queue <- item:
+		return nil
+	default:
+	}
+	switch q.overflow {
+	case OverflowDisconnect:
+		e.Close(DisconnectSlowConsumer)
+	case OverflowBlock:
+		t := time.NewTimer(q.timeout)
+		defer t.Stop()
+		select {
+		case q.queue <- item:
+			return nil
+		case <-q.done:
+			return ErrSessionClosed
+		case <-t.C:
+		}
+	}
+	if q.onDrop != nil {
+		q.onDrop()
+	}
+	return ErrQueueFull
+}
+
+// 写协程, 握手的回应写出后, 之后的消息都加密
+func (e *WebSocketAgent) writeLoop() {
+	q := e.writer
+	defer close(q.done)
+	var cipher *SessionCipher
+	for {
+		select {
+		case item := <-q.queue:
+			if !e.write(item, &cipher) {
+				return
+			}
+		case <-q.stop:
+			for {
+				select {
+				case item := <-q.queue:
+					if !e.write(item, &cipher) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *WebSocketAgent) write(item writeItem, cipher **SessionCipher) bool {
+	if item.close {
+		e.Conn.Close()
+		return false
+	}
+	if e.WriteTimeout > 0 {
+		e.Conn.SetWriteDeadline(time.Now().Add(e.WriteTimeout))
+	}
+	var err error
+	if item.ping {
+		err = pingCodec.Send(e.Conn, nil)
+	} else {
+		buff := item.buff
+		if *cipher != nil {
+			buff, err = (*cipher).Seal(buff)
+		}
+		if err == nil {
+			err = websocket.Message.Send(e.Conn, buff)
+		}
+	}
+	if err != nil {
+		e.Close(DisconnectError)
+		return false
+	}
+	if item.cipher != nil {
+		*cipher = item.cipher
+	}
+	return true
+}
+
+// 通知写协程写完剩余的消息后退出, 最多等待timeout
+func (e *WebSocketAgent) stopWriter(timeout time.Duration) {
+	q := e.writer
+	select {
+	case <-q.stop:
+	default:
+		close(q.stop)
+	}
+	if timeout <= 0 {
+		<-q.done
+		return
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-q.done:
+	case <-t.C:
+	}
+}
+
+// QueueLen 发送队列中等待的消息数量
+func (e *WebSocketAgent) QueueLen() int {
+	if e.writer == nil {
+		return 0
+	}
+	return len(e.writer.queue)
+}
@@ -0,0 +1,227 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"github.com/team-zf/framework/logger"
+	"github.com/team-zf/framework/modules"
+	"github.com/team-zf/framework/utils/threads"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AdminModule 管理控制台, 通过Unix Socket或本机TCP接收按行发送的命令
+type AdminModule struct {
+	name         string
+	addr         string
+	token        string // 连接后需要先发送 auth <token>, 监听TCP时必须设置
+	app          modules.IApp
+	listener     net.Listener
+	thgo         *threads.ThreadGo
+	lock         sync.RWMutex
+	commands     map[string]*Command
+	conns        map[net.Conn]bool
+	stopped      bool // 已停止, 不再接受新的连接
+	onlineCount  int64 // 当前连接数
+	commandCount int64 // 执行的命令总数
+}
+
+func (e *AdminModule) SetApp(app modules.IApp) {
+	e.app = app
+}
+
+func (e *AdminModule) Init() error {
+	if e.app == nil {
+		return fmt.Errorf("没有设置App")
+	}
+	return nil
+}
+
+func (e *AdminModule) Start() error {
+	network, address := parseAddr(e.addr)
+	if network == "unix" {
+		// 上次没有正常退出时会留下socket文件, 只删除socket, 防止配置写错时删掉其它文件
+		if info, err := os.Lstat(address); err == nil {
+			if info.Mode()&os.ModeSocket == 0 {
+				return fmt.Errorf("%s已存在且不是socket文件", address)
+			}
+			// 能连接上说明有正在运行的实例, 不能删除它的socket
+			if conn, err := net.DialTimeout("unix", address, time.Second); err == nil {
+				conn.Close()
+				return fmt.Errorf("%s正在被其它实例使用", address)
+			}
+			os.Remove(address)
+		}
+	} else if e.token == "" {
+		// 本机的其它用户都能连接TCP端口
+		return fmt.Errorf("管理控制台监听TCP时必须设置token")
+	}
+	ln, err := listen(e.addr)
+	if err != nil {
+		return err
+	}
+	e.listener = ln
+	e.thgo.Go(func(ctx context.Context) {
+		logger.Notice("%s启动: %s", e.name, e.addr)
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			e.thgo.Go(func(ctx context.Context) {
+				e.Handle(conn)
+			})
+		}
+	})
+	return nil
+}
+
+func (e *AdminModule) Stop() error {
+	err := e.listener.Close()
+	e.lock.Lock()
+	e.stopped = true
+	for conn := range e.conns {
+		conn.Close()
+	}
+	e.lock.Unlock()
+	e.thgo.CloseWait()
+	logger.Notice("%s已停止", e.name)
+	return err
+}
+
+func (e *AdminModule) Name() string {
+	return e.name
+}
+
+func (e *AdminModule) Status() *modules.ModuleStatus {
+	return modules.NewModuleStatus(e.name).
+		Gauge("onlineCount", float64(atomic.LoadInt64(&e.onlineCount))).
+		Counter("commandCount", atomic.LoadInt64(&e.commandCount))
+}
+
+// RegisterCommand 注册命令, 同名的命令会被替换
+func (e *AdminModule) RegisterCommand(name, usage, help string, fn CommandFunc) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.commands[name] = &Command{
+		Name:  name,
+		Usage: usage,
+		Help:  help,
+		Func:  fn,
+	}
+}
+
+// Exec 执行一行命令
+func (e *AdminModule) Exec(line string) (result string, err error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("命令为空")
+	}
+	e.lock.RLock()
+	cmd, ok := e.commands[fields[0]]
+	e.lock.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("未知命令: %s, 输入help查看所有命令", fields[0])
+	}
+
+	atomic.AddInt64(&e.commandCount, 1)
+	threads.Try(func() {
+		result, err = cmd.Func(fields[1:])
+	}, func(perr error) {
+		err = fmt.Errorf("命令执行出错: %v", perr)
+	})
+	return
+}
+
+func (e *AdminModule) Handle(conn net.Conn) {
+	defer conn.Close()
+	e.lock.Lock()
+	if e.stopped {
+		// 停止前已经Accept的连接, 停止时没有关闭它
+		e.lock.Unlock()
+		return
+	}
+	e.conns[conn] = true
+	e.lock.Unlock()
+	atomic.AddInt64(&e.onlineCount, 1)
+	defer func() {
+		e.lock.Lock()
+		delete(e.conns, conn)
+		e.lock.Unlock()
+		atomic.AddInt64(&e.onlineCount, -1)
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	if e.token != "" && !e.auth(conn, scanner) {
+		return
+	}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if line == "quit" || line == "exit" {
+			return
+		}
+		logger.Notice("%s执行命令: %s", e.name, line)
+		result, err := e.Exec(line)
+		if err := writeReply(conn, result, err); err != nil {
+			return
+		}
+	}
+}
+
+// 校验连接发送的第一行 auth <token>
+func (e *AdminModule) auth(conn net.Conn, scanner *bufio.Scanner) bool {
+	if !scanner.Scan() {
+		return false
+	}
+	fields := strings.Fields(scanner.Text())
+	if len(fields) != 2 || fields[0] != "auth" || subtle.ConstantTimeCompare([]byte(fields[1]), []byte(e.token)) != 1 {
+		logger.Warn("%s认证失败: %s", e.name, conn.RemoteAddr())
+		writeReply(conn, "", fmt.Errorf("认证失败"))
+		return false
+	}
+	return writeReply(conn, "", nil) == nil
+}
+
+func NewAdminModule(opts ...modules.ModOptions) *AdminModule {
+	result := &AdminModule{
+		name:     "Admin",
+		addr:     "unix:./admin.sock",
+		thgo:     threads.NewThreadGo(),
+		commands: make(map[string]*Command),
+		conns:    make(map[net.Conn]bool),
+	}
+	result.registerBuiltin()
+	for _, opt := range opts {
+		opt(result)
+	}
+	return result
+}
+
+func AdminSetName(v string) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*AdminModule).name = v
+	}
+}
+
+// 设置监听地址, unix:路径 为Unix Socket, 否则为本机TCP地址, 如 127.0.0.1:7070
+func AdminSetAddr(v string) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*AdminModule).addr = v
+	}
+}
+
+// 设置认证的token, 连接后需要先发送 auth <token>
+func AdminSetToken(v string) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*AdminModule).token = v
+	}
+}
@@ -0,0 +1,142 @@
+package admin
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 在临时目录中启动, 返回模块和unix地址
+func startTestAdmin(t *testing.T, opts ...func(*AdminModule)) (*AdminModule, string, func()) {
+	dir, err := ioutil.TempDir("", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "unix:" + filepath.Join(dir, "admin.sock")
+	mod := NewAdminModule(AdminSetAddr(addr))
+	for _, opt := range opts {
+		opt(mod)
+	}
+	mod.RegisterCommand("echo", "echo <text>", "原样输出", func(args []string) (string, error) {
+		return strings.Join(args, " "), nil
+	})
+	if err := mod.Start(); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return mod, addr, func() {
+		mod.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestAdminCommands(t *testing.T) {
+	_, addr, stop := startTestAdmin(t)
+	defer stop()
+
+	info, err := os.Stat(strings.TrimPrefix(addr, "unix:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("socket的权限不正确: %v", info.Mode())
+	}
+
+	client, err := Dial(addr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if result, err := client.Exec("echo .a b"); err != nil || result != ".a b" {
+		t.Fatalf("命令的结果不正确: %q %v", result, err)
+	}
+	if _, err := client.Exec("nope"); err == nil || !strings.Contains(err.Error(), "未知命令") {
+		t.Fatalf("未知命令应该返回错误: %v", err)
+	}
+	if result, err := client.Exec("help"); err != nil || !strings.Contains(result, "echo <text>") {
+		t.Fatalf("help的结果不正确: %q %v", result, err)
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	_, addr, stop := startTestAdmin(t, func(mod *AdminModule) { mod.token = "secret" })
+	defer stop()
+
+	if _, err := Dial(addr, "wrong"); err == nil || !strings.Contains(err.Error(), "认证失败") {
+		t.Fatalf("token错误时应该认证失败: %v", err)
+	}
+	// 没有认证时第一行命令被当作认证
+	client, err := Dial(addr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Exec("echo a"); err == nil {
+		t.Fatal("没有认证时不能执行命令")
+	}
+	client.Close()
+
+	client, err = Dial(addr, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if result, err := client.Exec("echo a"); err != nil || result != "a" {
+		t.Fatalf("命令的结果不正确: %q %v", result, err)
+	}
+
+	// 监听TCP时必须设置token
+	mod := NewAdminModule(AdminSetAddr("127.0.0.1:0"))
+	if err := mod.Start(); err == nil {
+		mod.Stop()
+		t.Fatal("没有token时不应该监听TCP")
+	}
+}
+
+func TestAdminSocketFile(t *testing.T) {
+	mod, addr, stop := startTestAdmin(t)
+	defer stop()
+	path := strings.TrimPrefix(addr, "unix:")
+
+	// 正在使用的socket不能删除
+	other := NewAdminModule(AdminSetAddr(addr))
+	if err := other.Start(); err == nil || !strings.Contains(err.Error(), "其它实例") {
+		t.Fatalf("socket正在使用时应该返回错误: %v", err)
+	}
+	// 停止后留下的socket可以删除
+	mod.listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	mod.Stop()
+	if _, err := os.Lstat(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Start(); err != nil {
+		t.Fatal(err)
+	}
+	other.Stop()
+
+	// 不是socket的文件不删除
+	ioutil.WriteFile(path, []byte("x"), 0644)
+	if err := other.Start(); err == nil {
+		t.Fatal("不是socket时不应该删除")
+	}
+	if buff, _ := ioutil.ReadFile(path); string(buff) != "x" {
+		t.Fatal("文件被删除了")
+	}
+}
+
+// 停止后不再接受连接
+func TestAdminHandleAfterStop(t *testing.T) {
+	mod, _, stop := startTestAdmin(t)
+	stop()
+	server, client := net.Pipe()
+	defer client.Close()
+	mod.Handle(server)
+	if len(mod.conns) != 0 {
+		t.Fatal("停止后不应该记录连接")
+	}
+	if _, err := client.Write([]byte("help\n")); err == nil {
+		t.Fatal("连接应该已经关闭")
+	}
+}
@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Client 管理控制台的客户端
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Dial 连接管理控制台, 地址格式与AdminSetAddr相同
+// token不为空时连接后先认证
+func Dial(addr, token string) (*Client, error) {
+	network, address := parseAddr(addr)
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	result := &Client{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+	if token != "" {
+		if _, err := result.Exec("auth " + token); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Exec 执行一行命令, 返回命令的输出
+func (e *Client) Exec(line string) (string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.ContainsAny(line, "\r\n") {
+		return "", fmt.Errorf("命令格式错误: %q", line)
+	}
+	if _, err := fmt.Fprintf(e.conn, "%s\n", line); err != nil {
+		return "", err
+	}
+	return readReply(e.reader)
+}
+
+func (e *Client) Close() error {
+	return e.conn.Close()
+}
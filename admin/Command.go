@@ -0,0 +1,160 @@
+package admin
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/team-zf/framework/logger"
+	"github.com/team-zf/framework/modules"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CommandFunc 命令的处理方法, args不含命令名, 返回的文本会原样输出给客户端
+type CommandFunc func(args []string) (string, error)
+
+// Command 管理命令
+type Command struct {
+	Name  string
+	Usage string // 用法, 如 "loglevel <0-7>"
+	Help  string
+	Func  CommandFunc
+}
+
+// 注册框架内置的命令
+func (e *AdminModule) registerBuiltin() {
+	e.RegisterCommand("help", "help", "列出所有命令", e.cmdHelp)
+	e.RegisterCommand("modules", "modules", "按启动顺序列出所有模块", e.cmdModules)
+	e.RegisterCommand("status", "status", "输出所有模块的状态", e.cmdStatus)
+	e.RegisterCommand("reload", "reload config|tables", "重新载入配置或数据表", e.cmdReload)
+	e.RegisterCommand("config", "config", "输出合并后的配置(已隐藏敏感字段)", e.cmdConfig)
+	e.RegisterCommand("loglevel", "loglevel <0-7>", "调整日志级别", e.cmdLogLevel)
+	e.RegisterCommand("goroutines", "goroutines", "输出所有协程的调用栈", e.cmdGoroutines)
+	e.RegisterCommand("kick", "kick <addr|session:id|user:id>", "断开指定的连接", e.cmdKick)
+	e.RegisterCommand("flush", "flush", "立刻保存所有模块缓存的数据", e.cmdFlush)
+	e.RegisterCommand("maintenance", "maintenance [on|off]", "查看或切换维护模式", e.cmdMaintenance)
+}
+
+func (e *AdminModule) cmdHelp(args []string) (string, error) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	names := make([]string, 0, len(e.commands))
+	for name := range e.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	buff := &bytes.Buffer{}
+	for _, name := range names {
+		cmd := e.commands[name]
+		fmt.Fprintf(buff, "%-24s %s\n", cmd.Usage, cmd.Help)
+	}
+	fmt.Fprintf(buff, "%-24s %s\n", "quit", "断开连接")
+	return buff.String(), nil
+}
+
+func (e *AdminModule) cmdModules(args []string) (string, error) {
+	buff := &bytes.Buffer{}
+	for i, md := range e.app.Modules() {
+		name := ""
+		if named, ok := md.(modules.IModuleName); ok {
+			name = named.Name()
+		}
+		fmt.Fprintf(buff, "%d\t%s\t%T\n", i, name, md)
+	}
+	return buff.String(), nil
+}
+
+func (e *AdminModule) cmdStatus(args []string) (string, error) {
+	buff := &bytes.Buffer{}
+	for _, status := range e.app.Status() {
+		buff.WriteString(strings.TrimLeft(status.String(), "\r\n\t"))
+		buff.WriteString("\n")
+	}
+	return buff.String(), nil
+}
+
+func (e *AdminModule) cmdReload(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("用法: reload config|tables")
+	}
+	var err error
+	switch args[0] {
+	case "config":
+		err = e.app.ReloadConfig()
+	case "tables":
+		err = e.app.ReloadTables()
+	default:
+		return "", fmt.Errorf("用法: reload config|tables")
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s已重新载入", args[0]), nil
+}
+
+func (e *AdminModule) cmdConfig(args []string) (string, error) {
+	return e.app.GetConfig().Redacted() + "\n", nil
+}
+
+func (e *AdminModule) cmdLogLevel(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("用法: loglevel <0-7>")
+	}
+	level, err := strconv.Atoi(args[0])
+	if err != nil || level < 0 || level > 7 {
+		return "", fmt.Errorf("日志级别必须在0~7之间")
+	}
+	logger.SetLevel(level)
+	return fmt.Sprintf("日志级别已调整为%d", level), nil
+}
+
+func (e *AdminModule) cmdGoroutines(args []string) (string, error) {
+	buff := &bytes.Buffer{}
+	if err := pprof.Lookup("goroutine").WriteTo(buff, 2); err != nil {
+		return "", err
+	}
+	return buff.String(), nil
+}
+
+func (e *AdminModule) cmdKick(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("用法: kick <addr|session:id|user:id>")
+	}
+	for _, md := range e.app.Modules() {
+		if kick, ok := md.(modules.IModuleKick); ok && kick.Kick(args[0]) {
+			return fmt.Sprintf("已断开%s", args[0]), nil
+		}
+	}
+	return "", fmt.Errorf("没有找到连接: %s", args[0])
+}
+
+func (e *AdminModule) cmdFlush(args []string) (string, error) {
+	count := 0
+	for _, md := range e.app.Modules() {
+		if flush, ok := md.(modules.IModuleFlush); ok {
+			if err := flush.Flush(); err != nil {
+				return "", err
+			}
+			count++
+		}
+	}
+	return fmt.Sprintf("已保存%d个模块的数据", count), nil
+}
+
+func (e *AdminModule) cmdMaintenance(args []string) (string, error) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "on":
+			e.app.SetMaintenance(true)
+		case "off":
+			e.app.SetMaintenance(false)
+		default:
+			return "", fmt.Errorf("用法: maintenance [on|off]")
+		}
+	}
+	if e.app.Maintenance() {
+		return "维护模式: on", nil
+	}
+	return "维护模式: off", nil
+}
@@ -0,0 +1,22 @@
+package admin
+
+import (
+	"github.com/team-zf/framework/config"
+	"github.com/team-zf/framework/modules"
+)
+
+func init() {
+	modules.RegisterModuleType("admin", newAdminModuleFromConfig)
+}
+
+func newAdminModuleFromConfig(app modules.IApp, conf *config.ModuleConfig) (modules.IModule, error) {
+	result := NewAdminModule()
+	if conf.Name != "" {
+		result.name = conf.Name
+	}
+	if conf.Addr != "" {
+		result.addr = conf.Addr
+	}
+	result.token = conf.Settings.GetString("Token", "")
+	return result, nil
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package admin
+
+import (
+	"net"
+	"syscall"
+)
+
+// 创建只有当前用户能访问的socket文件, 创建时就是0600, 不存在其它用户可以连接的时间窗口
+// umask是整个进程的, 这期间其它协程创建的文件也只有当前用户能访问
+func listenUnix(address string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", address)
+}
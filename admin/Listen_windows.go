@@ -0,0 +1,10 @@
+package admin
+
+import (
+	"net"
+)
+
+// Windows没有umask, 由目录的权限控制访问
+func listenUnix(address string) (net.Listener, error) {
+	return net.Listen("unix", address)
+}
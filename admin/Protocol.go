@@ -0,0 +1,103 @@
+package admin
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+/**
+ * 管理控制台协议, 按行收发:
+ * 请求: 一行命令, 参数以空格分隔
+ * 回复: 首行为 OK 或 ERR 原因, 之后是内容, 以单独一行 . 结束
+ *       内容中以 . 开头的行会再加一个 . (与SMTP相同)
+ */
+
+const (
+	replyOK  = "OK"
+	replyErr = "ERR"
+	replyEnd = "."
+)
+
+// 写回复
+func writeReply(w io.Writer, text string, err error) error {
+	bw := bufio.NewWriter(w)
+	if err != nil {
+		fmt.Fprintf(bw, "%s %s\n", replyErr, strings.Replace(err.Error(), "\n", " ", -1))
+	} else {
+		fmt.Fprintf(bw, "%s\n", replyOK)
+	}
+	text = strings.TrimRight(text, "\n")
+	if text != "" {
+		for _, line := range strings.Split(text, "\n") {
+			if strings.HasPrefix(line, ".") {
+				line = "." + line
+			}
+			fmt.Fprintf(bw, "%s\n", line)
+		}
+	}
+	fmt.Fprintf(bw, "%s\n", replyEnd)
+	return bw.Flush()
+}
+
+// 读回复, 命令执行失败时返回的错误包含原因
+func readReply(r *bufio.Reader) (string, error) {
+	head, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	head = strings.TrimRight(head, "\r\n")
+	var result error
+	switch {
+	case head == replyOK:
+	case strings.HasPrefix(head, replyErr):
+		result = errors.New(strings.TrimSpace(strings.TrimPrefix(head, replyErr)))
+	default:
+		return "", fmt.Errorf("无法识别的回复: %s", head)
+	}
+
+	lines := make([]string, 0)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == replyEnd {
+			break
+		}
+		if strings.HasPrefix(line, "..") {
+			line = line[1:]
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), result
+}
+
+// 按地址监听, unix:路径 为Unix Socket, 否则为只允许本机访问的TCP地址
+func listen(addr string) (net.Listener, error) {
+	network, address := parseAddr(addr)
+	if network == "tcp" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return nil, fmt.Errorf("管理控制台只能监听本机地址: %s", addr)
+		}
+	}
+	if network == "unix" {
+		return listenUnix(address)
+	}
+	return net.Listen(network, address)
+}
+
+func parseAddr(addr string) (network, address string) {
+	if strings.HasPrefix(addr, "unix:") {
+		return "unix", strings.TrimPrefix(addr, "unix:")
+	}
+	return "tcp", addr
+}
@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReply(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		err  error
+		wire string // 写出的内容
+		want string // 读回的内容
+	}{
+		{"空的回复", "", nil, "OK\n.\n", ""},
+		{"多行内容", "a\nb\n", nil, "OK\na\nb\n.\n", "a\nb"},
+		{"以.开头的行", ".\n..x\ny", nil, "OK\n..\n...x\ny\n.\n", ".\n..x\ny"},
+		{"错误", "", errors.New("参数\n错误"), "ERR 参数 错误\n.\n", ""},
+		{"错误带内容", "usage", errors.New("bad"), "ERR bad\nusage\n.\n", "usage"},
+	}
+	for _, test := range tests {
+		buff := &bytes.Buffer{}
+		if err := writeReply(buff, test.text, test.err); err != nil {
+			t.Fatal(err)
+		}
+		if buff.String() != test.wire {
+			t.Errorf("%s: 写出的内容不正确: %q", test.name, buff.String())
+			continue
+		}
+		text, err := readReply(bufio.NewReader(buff))
+		if text != test.want || (err == nil) != (test.err == nil) {
+			t.Errorf("%s: 读回的内容不正确: %q %v", test.name, text, err)
+		}
+		if test.err != nil && err != nil && err.Error() != strings.Replace(test.err.Error(), "\n", " ", -1) {
+			t.Errorf("%s: 错误不正确: %v", test.name, err)
+		}
+	}
+
+	// 不完整的回复和无法识别的首行
+	for _, wire := range []string{"OK\na\n", "HELLO\n.\n", ""} {
+		if _, err := readReply(bufio.NewReader(bytes.NewBufferString(wire))); err == nil {
+			t.Errorf("%q: 应该返回错误", wire)
+		}
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:7070", "192.168.1.1:7070", "7070"} {
+		if ln, err := listen(addr); err == nil {
+			ln.Close()
+			t.Errorf("%s: 不应该允许监听", addr)
+		}
+	}
+	ln, err := listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+}
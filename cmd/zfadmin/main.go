@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/team-zf/framework/admin"
+	"os"
+	"strings"
+)
+
+// 管理控制台的命令行客户端
+// 执行单条命令: zfadmin -a unix:./admin.sock status
+// 交互模式:     zfadmin -a 127.0.0.1:7070 -t token
+func main() {
+	addr := flag.String("a", "unix:./admin.sock", "管理控制台地址")
+	token := flag.String("t", os.Getenv("ZFADMIN_TOKEN"), "认证的token, 默认读取环境变量ZFADMIN_TOKEN")
+	flag.Parse()
+
+	client, err := admin.Dial(*addr, *token)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "连接失败: %v\n", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	if flag.NArg() > 0 {
+		result, err := client.Exec(strings.Join(flag.Args(), " "))
+		if result != "" {
+			fmt.Println(result)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			client.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	fmt.Print("> ")
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "":
+		case "quit", "exit":
+			return
+		default:
+			result, err := client.Exec(line)
+			if result != "" {
+				fmt.Println(result)
+			}
+			if err != nil {
+				fmt.Printf("错误: %v\n", err)
+			}
+		}
+		fmt.Print("> ")
+	}
+}
@@ -1,8 +1,20 @@
 package config
 
 type AppConfig struct {
-	Settings map[string]interface{}
-	Logger   *LoggerConfig
-	Table    *TableConfig
-	Redis    *RedisConfig
+	Settings  Settings
+	Logger    *LoggerConfig
+	Table     *TableConfig
+	Redis     *RedisConfig
+	MySql     *MySqlConfig
+	DataBase  *DataBaseConfig
+	Http      *HttpConfig
+	WebSocket *WebSocketConfig
+	Modules   []*ModuleConfig
+
+	ignoredEnv []string // 没有对应字段的环境变量
+}
+
+// IgnoredEnv 载入时没有对应字段而被忽略的环境变量
+func (e *AppConfig) IgnoredEnv() []string {
+	return e.ignoredEnv
 }
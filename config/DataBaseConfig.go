@@ -0,0 +1,20 @@
+package config
+
+// DataBaseConfig 数据库模块缓存写入的配置
+type DataBaseConfig struct {
+	FlushInterval int // 缓存写入数据库的间隔(毫秒)
+	QueueSize     int // 消息信道的长度
+	DegradeCount  int // 连续保存失败多少次后报告为异常, 为负数时不报告
+}
+
+func (e *DataBaseConfig) setDefaults() {
+	if e.FlushInterval == 0 {
+		e.FlushInterval = 1000
+	}
+	if e.QueueSize == 0 {
+		e.QueueSize = 1024
+	}
+	if e.DegradeCount == 0 {
+		e.DegradeCount = 3
+	}
+}
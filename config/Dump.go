@@ -0,0 +1,78 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const redactedValue = "******"
+
+var (
+	// 敏感字段的名称(不区分大小写, 包含即可), key包含SignKey等私钥
+	sensitiveKeys = []string{"password", "passwd", "pwd", "secret", "token", "key"}
+)
+
+// Redacted 输出合并后的配置, 密码等敏感字段已隐藏, 用于排查配置
+func (e *AppConfig) Redacted() string {
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return err.Error()
+	}
+	var data interface{}
+	json.Unmarshal(bytes, &data)
+	bytes, _ = json.MarshalIndent(redact(data), "", "    ")
+	return string(bytes)
+}
+
+func redact(data interface{}) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			lower := strings.ToLower(key)
+			switch {
+			case strings.Contains(lower, "dsn"):
+				if s, ok := item.(string); ok {
+					v[key] = redactDsn(s)
+				}
+			case isSensitive(lower):
+				if item != nil && item != "" {
+					v[key] = redactedValue
+				}
+			default:
+				v[key] = redact(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redact(item)
+		}
+	}
+	return data
+}
+
+func isSensitive(key string) bool {
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// 隐藏mysql格式的dsn中的密码, user:password@tcp(...)/dbname
+// 与驱动的解析方式相同, 取数据库名前最后一个@, 密码中可以有@和:
+func redactDsn(dsn string) string {
+	end := len(dsn)
+	if i := strings.LastIndex(dsn, "/"); i >= 0 {
+		end = i
+	}
+	at := strings.LastIndex(dsn[:end], "@")
+	if at < 0 {
+		return dsn
+	}
+	colon := strings.Index(dsn[:at], ":")
+	if colon < 0 {
+		return dsn
+	}
+	return dsn[:colon+1] + redactedValue + dsn[at:]
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactDsn(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"u:pass@tcp(h:3306)/db", "u:******@tcp(h:3306)/db"},
+		{"u:p@ss@tcp(h)/db", "u:******@tcp(h)/db"},
+		{"u:p:a@s/s@tcp(h)/db?charset=utf8", "u:******@tcp(h)/db?charset=utf8"},
+		{"u@tcp(h)/db", "u@tcp(h)/db"},
+		{"/db", "/db"},
+	}
+	for _, test := range tests {
+		if result := redactDsn(test.dsn); result != test.want {
+			t.Errorf("%s: %s", test.dsn, result)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	conf := &AppConfig{
+		MySql:     &MySqlConfig{Dsn: "root:p@ss@tcp(db)/game"},
+		Redis:     &RedisConfig{Addr: "redis:6379", Password: "redis-pass"},
+		Settings:  Settings{"api": map[string]interface{}{"Token": "abc", "name": "game"}},
+		WebSocket: &WebSocketConfig{SignKey: "c2lnbi1rZXktc2VlZA=="},
+		Modules:   []*ModuleConfig{{Type: "websocket", Settings: Settings{"SignKey": "bW9kdWxlLXNpZ24ta2V5"}}},
+	}
+	dump := conf.Redacted()
+	for _, secret := range []string{"p@ss", "ss@tcp", "redis-pass", "abc", "c2lnbi1rZXktc2VlZA==", "bW9kdWxlLXNpZ24ta2V5"} {
+		if strings.Contains(dump, secret) {
+			t.Fatalf("没有隐藏%s: %s", secret, dump)
+		}
+	}
+	if !strings.Contains(dump, "redis:6379") || !strings.Contains(dump, "game") {
+		t.Fatalf("不敏感的字段不应该隐藏: %s", dump)
+	}
+}
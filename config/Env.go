@@ -0,0 +1,178 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ApplyEnv 用环境变量覆盖配置, 变量名为 前缀+字段路径, 路径以下划线分隔, 不区分大小写
+// 如 ZF_REDIS_ADDR -> Redis.Addr, ZF_LOGGER_FILE_LEVEL -> Logger.File.Level
+// 字段名中间也可以加下划线, 如 ZF_REDIS_MAX_ACTIVE -> Redis.MaxActive
+// Settings中不存在的字段会以小写名称新增; 对象和数组的值按JSON解析
+// 没有对应字段的变量不会报错, 返回在ignored中, 部署环境中可能有同样前缀的其它变量
+func ApplyEnv(conf *AppConfig, prefix string, environ []string) (ignored []string, err error) {
+	// 排序保证多个变量覆盖同一字段时的结果是固定的
+	sorted := append([]string(nil), environ...)
+	sort.Strings(sorted)
+	for _, kv := range sorted {
+		i := strings.Index(kv, "=")
+		if i < 0 || !strings.HasPrefix(strings.ToUpper(kv[:i]), strings.ToUpper(prefix)) {
+			continue
+		}
+		name, value := kv[:i], kv[i+1:]
+		if len(name) == len(prefix) {
+			continue
+		}
+		path := strings.Split(name[len(prefix):], "_")
+		if err := setPath(reflect.ValueOf(conf).Elem(), path, value); errors.Is(err, errNoField) {
+			ignored = append(ignored, name)
+		} else if err != nil {
+			return nil, fmt.Errorf("环境变量%s: %v", name, err)
+		}
+	}
+	return ignored, nil
+}
+
+// 路径没有对应的字段
+var errNoField = errors.New("没有对应的字段")
+
+// 按路径设置字段的值
+// 为nil的指针先在新的对象上设置, 整个路径都有对应的字段并设置成功后才赋值
+// 这样没有对应字段的变量不会创建出空的配置部分
+func setPath(v reflect.Value, path []string, value string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			ptr := reflect.New(v.Type().Elem())
+			if err := setPath(ptr.Elem(), path, value); err != nil {
+				return err
+			}
+			v.Set(ptr)
+			return nil
+		}
+		v = v.Elem()
+	}
+	if len(path) == 0 {
+		return setValue(v, value)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		// 先匹配最长的名称, 这样 MAX_ACTIVE 可以对应 MaxActive
+		for n := len(path); n > 0; n-- {
+			name := strings.Join(path[:n], "")
+			for i := 0; i < v.NumField(); i++ {
+				field := v.Type().Field(i)
+				if field.PkgPath != "" {
+					continue
+				}
+				tag := strings.Split(field.Tag.Get("json"), ",")[0]
+				if strings.EqualFold(field.Name, name) || (tag != "" && strings.EqualFold(tag, name)) {
+					return setPath(v.Field(i), path[n:], value)
+				}
+			}
+		}
+		return fmt.Errorf("%w: %s", errNoField, strings.Join(path, "_"))
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.Interface {
+			break
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		setMapPath(v.Convert(reflect.TypeOf(map[string]interface{}{})).Interface().(map[string]interface{}), path, value)
+		return nil
+	}
+	return fmt.Errorf("%w: %s不能按路径设置", errNoField, v.Type())
+}
+
+// 按路径设置map中的值, 已有的字段不区分大小写匹配
+func setMapPath(m map[string]interface{}, path []string, value string) {
+	for n := len(path); n > 0; n-- {
+		for _, name := range []string{strings.Join(path[:n], "_"), strings.Join(path[:n], "")} {
+			key, ok := findKey(m, name)
+			if !ok {
+				continue
+			}
+			if n == len(path) {
+				m[key] = parseValue(value)
+				return
+			}
+			if sub, ok := m[key].(map[string]interface{}); ok {
+				setMapPath(sub, path[n:], value)
+				return
+			}
+		}
+	}
+	// 不存在的字段, 除最后一段外都作为对象创建
+	key := strings.ToLower(path[0])
+	if len(path) == 1 {
+		m[key] = parseValue(value)
+		return
+	}
+	sub := make(map[string]interface{})
+	m[key] = sub
+	setMapPath(sub, path[1:], value)
+}
+
+// 没有类型信息的值, 能按JSON解析时按JSON解析, 否则为字符串
+func parseValue(value string) interface{} {
+	var result interface{}
+	if err := json.Unmarshal([]byte(value), &result); err == nil {
+		return result
+	}
+	return value
+}
+
+// 按字段类型设置值
+func setValue(v reflect.Value, value string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		if d, err := time.ParseDuration(value); err == nil {
+			v.SetInt(int64(d))
+			return nil
+		}
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(parseValue(value)))
+	default:
+		// 对象, 数组, map按JSON解析
+		ptr := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(value), ptr.Interface()); err != nil {
+			return err
+		}
+		v.Set(ptr.Elem())
+	}
+	return nil
+}
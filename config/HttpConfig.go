@@ -0,0 +1,17 @@
+package config
+
+type HttpConfig struct {
+	Addr      string
+	Timeout   int              // 单个请求的超时时间(秒)
+	Codec     string           // 默认的编码方式, 如 json, msgpack
+	RateLimit *RateLimitConfig // 请求频率限制, 为空时不限制
+}
+
+func (e *HttpConfig) setDefaults() {
+	if e.Addr == "" {
+		e.Addr = ":8080"
+	}
+	if e.Timeout == 0 {
+		e.Timeout = 30
+	}
+}
@@ -0,0 +1,11 @@
+package config
+
+// ModuleConfig 在配置文件中声明的模块, 由注册过的模块类型创建
+type ModuleConfig struct {
+	Type      string   // 注册的模块类型, 如 http, websocket, database
+	Name      string   // 模块名称, 为空时使用模块的默认名称
+	DependsOn []string // 依赖的模块名称
+	Addr      string   // 监听地址
+	Dsn       string   // 数据库连接
+	Settings  Settings // 模块类型自己的参数
+}
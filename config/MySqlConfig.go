@@ -0,0 +1,38 @@
+package config
+
+// MySqlConfig 数据库连接的配置
+// 数量和时间的字段没有配置时使用默认值, 配置为0时按database/sql的含义: 不限制连接数, 不保留空闲连接, 连接不过期
+type MySqlConfig struct {
+	Dsn         string
+	MaxOpenNum  *int // 最大连接数, 默认100
+	MaxIdleNum  *int // 最大空闲连接数, 默认50, 不超过MaxOpenNum
+	MaxLifetime *int // 连接的最长使用时间(秒), 默认600
+}
+
+func (e *MySqlConfig) setDefaults() {
+	if e.MaxOpenNum == nil {
+		e.MaxOpenNum = intPtr(100)
+	}
+	if e.MaxIdleNum == nil {
+		idle := 50
+		if open := *e.MaxOpenNum; open > 0 && idle > open {
+			idle = open
+		}
+		e.MaxIdleNum = &idle
+	}
+	if e.MaxLifetime == nil {
+		e.MaxLifetime = intPtr(600)
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+// 没有配置时为0
+func intValue(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
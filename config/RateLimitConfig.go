@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// LimitConfig 令牌桶的参数, Rate为0时不限制
+type LimitConfig struct {
+	Rate  float64 // 每秒允许的请求数
+	Burst int     // 最多可以连续发送的请求数, 为0时等于Rate
+}
+
+type RateLimitConfig struct {
+	Session  LimitConfig            // 每个连接, Http没有连接, 按IP限制
+	IP       LimitConfig            // 每个IP
+	Cmd      LimitConfig            // 每个连接的每个cmd
+	Cmds     map[string]LimitConfig // 按cmd覆盖Cmd的限制, 键为cmd
+	BanCount int                    // 一分钟内被限制多少次后断开连接, 为0时不断开
+	BanTime  int                    // 断开后封禁IP的时间(秒), 为0时不封禁
+	// 可信的代理(如CDN的回源地址), CIDR或IP, 只有来自这些地址的请求才使用X-Forwarded-For和X-Real-IP中的客户端IP
+	TrustedProxies []string
+}
+
+func (e *RateLimitConfig) validate(prefix string) error {
+	limits := map[string]LimitConfig{
+		"Session": e.Session,
+		"IP":      e.IP,
+		"Cmd":     e.Cmd,
+	}
+	for cmd, limit := range e.Cmds {
+		var v uint32
+		if _, err := fmt.Sscanf(cmd, "%d", &v); err != nil {
+			return fmt.Errorf("%s.Cmds: cmd必须是数字, 当前为%q", prefix, cmd)
+		}
+		limits["Cmds."+cmd] = limit
+	}
+	for name, limit := range limits {
+		if limit.Rate < 0 {
+			return fmt.Errorf("%s.%s.Rate: 不能小于0, 当前为%v", prefix, name, limit.Rate)
+		}
+		if limit.Burst < 0 {
+			return fmt.Errorf("%s.%s.Burst: 不能小于0, 当前为%d", prefix, name, limit.Burst)
+		}
+	}
+	if e.BanCount < 0 {
+		return fmt.Errorf("%s.BanCount: 不能小于0, 当前为%d", prefix, e.BanCount)
+	}
+	if e.BanTime < 0 {
+		return fmt.Errorf("%s.BanTime: 不能小于0, 当前为%d", prefix, e.BanTime)
+	}
+	for i, cidr := range e.TrustedProxies {
+		if _, err := ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("%s.TrustedProxies[%d]: %v", prefix, i, err)
+		}
+	}
+	return nil
+}
+
+// ParseCIDR 解析CIDR, 单独的IP作为只包含这个IP的网段
+func ParseCIDR(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("不是IP或CIDR: %q", s)
+		}
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}, nil
+	}
+	_, result, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("不是IP或CIDR: %q", s)
+	}
+	return result, nil
+}
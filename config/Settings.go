@@ -0,0 +1,211 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Settings 业务自定义的配置, 支持用 "a.b.c" 的路径读取嵌套的字段, 字段名不区分大小写
+type Settings map[string]interface{}
+
+// Get 按路径取值, 路径为空时返回整个Settings
+func (e Settings) Get(path string) (interface{}, bool) {
+	if path == "" {
+		return map[string]interface{}(e), e != nil
+	}
+	var cur interface{} = map[string]interface{}(e)
+	for _, name := range strings.Split(path, ".") {
+		m, ok := asMap(cur)
+		if !ok {
+			return nil, false
+		}
+		key, ok := findKey(m, name)
+		if !ok {
+			return nil, false
+		}
+		cur = m[key]
+	}
+	return cur, true
+}
+
+// Has 是否存在指定的字段
+func (e Settings) Has(path string) bool {
+	_, ok := e.Get(path)
+	return ok
+}
+
+func (e Settings) GetString(path string, def string) string {
+	v, ok := e.Get(path)
+	if !ok || v == nil {
+		return def
+	}
+	switch s := v.(type) {
+	case string:
+		return s
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(s)
+	}
+	return def
+}
+
+func (e Settings) GetInt(path string, def int) int {
+	return int(e.GetInt64(path, int64(def)))
+}
+
+func (e Settings) GetInt64(path string, def int64) int64 {
+	v, ok := e.Get(path)
+	if !ok {
+		return def
+	}
+	switch n := v.(type) {
+	case float64:
+		return int64(n)
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	case string:
+		if i, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64); err == nil {
+			return i
+		}
+	}
+	return def
+}
+
+func (e Settings) GetFloat(path string, def float64) float64 {
+	v, ok := e.Get(path)
+	if !ok {
+		return def
+	}
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(n), 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
+
+func (e Settings) GetBool(path string, def bool) bool {
+	v, ok := e.Get(path)
+	if !ok {
+		return def
+	}
+	switch b := v.(type) {
+	case bool:
+		return b
+	case float64:
+		return b != 0
+	case string:
+		if r, err := strconv.ParseBool(strings.TrimSpace(b)); err == nil {
+			return r
+		}
+	}
+	return def
+}
+
+// GetDuration 字符串按 "1m30s" 的格式解析, 数字按秒计算
+func (e Settings) GetDuration(path string, def time.Duration) time.Duration {
+	v, ok := e.Get(path)
+	if !ok {
+		return def
+	}
+	switch d := v.(type) {
+	case float64:
+		return time.Duration(d * float64(time.Second))
+	case int:
+		return time.Duration(d) * time.Second
+	case int64:
+		return time.Duration(d) * time.Second
+	case string:
+		if r, err := time.ParseDuration(strings.TrimSpace(d)); err == nil {
+			return r
+		}
+	}
+	return def
+}
+
+// GetStringSlice 支持数组, 或者用逗号分隔的字符串
+func (e Settings) GetStringSlice(path string, def []string) []string {
+	v, ok := e.Get(path)
+	if !ok || v == nil {
+		return def
+	}
+	switch arr := v.(type) {
+	case []string:
+		return arr
+	case []interface{}:
+		result := make([]string, 0, len(arr))
+		for _, item := range arr {
+			switch s := item.(type) {
+			case string:
+				result = append(result, s)
+			case float64:
+				result = append(result, strconv.FormatFloat(s, 'f', -1, 64))
+			case bool:
+				result = append(result, strconv.FormatBool(s))
+			default:
+				return def
+			}
+		}
+		return result
+	case string:
+		result := make([]string, 0)
+		for _, s := range strings.Split(arr, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return def
+}
+
+// GetSettings 取得嵌套的对象, 不存在时返回nil, 可以继续调用Get系列的方法
+func (e Settings) GetSettings(path string) Settings {
+	v, ok := e.Get(path)
+	if !ok {
+		return nil
+	}
+	if m, ok := asMap(v); ok {
+		return Settings(m)
+	}
+	return nil
+}
+
+// Bind 把指定路径下的内容按JSON规则填充到结构体中, 不存在时不修改v
+func (e Settings) Bind(path string, v interface{}) error {
+	value, ok := e.Get(path)
+	if !ok {
+		return nil
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("Settings.%s: %v", path, err)
+	}
+	if err := json.Unmarshal(bytes, v); err != nil {
+		return fmt.Errorf("Settings.%s: %v", path, err)
+	}
+	return nil
+}
+
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case Settings:
+		return map[string]interface{}(m), true
+	}
+	return nil, false
+}
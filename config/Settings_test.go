@@ -0,0 +1,69 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSettingsAccessors(t *testing.T) {
+	var s Settings
+	json.Unmarshal([]byte(`{
+		"Game": {"Max_Level": 60, "rate": 1.5, "open": true, "name": "zf", "tags": ["a", 1, true], "wait": "1m30s"},
+		"text": {"num": " 42 ", "float": "2.5", "bool": "false", "csv": "a, b,,c"},
+		"nums": {"int": 3, "zero": 0},
+		"null": null
+	}`), &s)
+	s["nums"].(map[string]interface{})["i64"] = int64(7)
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"路径不区分大小写", s.GetInt("game.max_level", 0), 60},
+		{"浮点数转整数", s.GetInt64("game.rate", 0), int64(1)},
+		{"字符串转整数", s.GetInt("text.num", 0), 42},
+		{"int64", s.GetInt("nums.i64", 0), 7},
+		{"字符串转浮点数", s.GetFloat("text.float", 0), 2.5},
+		{"整数转浮点数", s.GetFloat("nums.i64", 0), 7.0},
+		{"布尔值", s.GetBool("game.open", false), true},
+		{"字符串转布尔值", s.GetBool("text.bool", true), false},
+		{"数字转布尔值", s.GetBool("nums.zero", true), false},
+		{"数字转字符串", s.GetString("game.rate", ""), "1.5"},
+		{"布尔值转字符串", s.GetString("game.open", ""), "true"},
+		{"数字按秒计算", s.GetDuration("nums.int", 0), 3 * time.Second},
+		{"字符串时长", s.GetDuration("game.wait", 0), 90 * time.Second},
+		{"数组", s.GetStringSlice("game.tags", nil), []string{"a", "1", "true"}},
+		{"逗号分隔", s.GetStringSlice("text.csv", nil), []string{"a", "b", "c"}},
+		{"嵌套的对象", s.GetSettings("game").GetString("name", ""), "zf"},
+
+		{"不存在的字段", s.GetInt("game.nope", -1), -1},
+		{"不存在的上级", s.GetString("nope.name", "def"), "def"},
+		{"上级不是对象", s.GetString("game.name.x", "def"), "def"},
+		{"null使用默认值", s.GetString("null", "def"), "def"},
+		{"类型不能转换", s.GetInt("game.name", -1), -1},
+		{"格式错误", s.GetDuration("game.name", time.Second), time.Second},
+		{"数组中有对象", s.GetStringSlice("nums", []string{"x"}), []string{"x"}},
+		{"不存在的对象", s.GetSettings("nope") == nil, true},
+		{"Has", s.Has("GAME.NAME") && !s.Has("game.nope"), true},
+		{"nil的Settings", Settings(nil).GetInt("a", 5), 5},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%s: %#v, 应该为%#v", test.name, test.got, test.want)
+		}
+	}
+
+	var game struct {
+		MaxLevel int    `json:"max_level"`
+		Name     string `json:"name"`
+	}
+	if err := s.Bind("game", &game); err != nil || game.MaxLevel != 60 || game.Name != "zf" {
+		t.Fatalf("Bind的结果不正确: %+v %v", game, err)
+	}
+	if err := s.Bind("game.name", &game); err == nil {
+		t.Fatal("类型不一致时应该返回错误")
+	}
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// SetDefaults 给已配置的模块填充默认值, 未配置的部分保持为nil
+func (e *AppConfig) SetDefaults() {
+	if e.MySql != nil {
+		e.MySql.setDefaults()
+	}
+	if e.DataBase != nil {
+		e.DataBase.setDefaults()
+	}
+	if e.Http != nil {
+		e.Http.setDefaults()
+	}
+	if e.WebSocket != nil {
+		e.WebSocket.setDefaults()
+	}
+}
+
+// Validate 校验配置, 错误信息中包含出错的字段
+func (e *AppConfig) Validate() error {
+	if e.Logger == nil {
+		return fmt.Errorf("Logger: 缺少日志配置")
+	}
+	if file := e.Logger.File; file != nil {
+		if file.Level < 0 || file.Level > 7 {
+			return fmt.Errorf("Logger.File.Level: 日志级别必须在0~7之间, 当前为%d", file.Level)
+		}
+	}
+	if mysql := e.MySql; mysql != nil {
+		if mysql.Dsn == "" {
+			return fmt.Errorf("MySql.Dsn: 不能为空")
+		}
+		open, idle, lifetime := intValue(mysql.MaxOpenNum), intValue(mysql.MaxIdleNum), intValue(mysql.MaxLifetime)
+		if open < 0 {
+			return fmt.Errorf("MySql.MaxOpenNum: 不能小于0, 当前为%d", open)
+		}
+		if idle < 0 || (open > 0 && idle > open) {
+			return fmt.Errorf("MySql.MaxIdleNum: 必须在0~MaxOpenNum(%d)之间, 当前为%d", open, idle)
+		}
+		if lifetime < 0 {
+			return fmt.Errorf("MySql.MaxLifetime: 不能小于0, 当前为%d", lifetime)
+		}
+	}
+	if db := e.DataBase; db != nil {
+		if db.FlushInterval < 0 {
+			return fmt.Errorf("DataBase.FlushInterval: 不能小于0, 当前为%d", db.FlushInterval)
+		}
+		if db.QueueSize < 0 {
+			return fmt.Errorf("DataBase.QueueSize: 不能小于0, 当前为%d", db.QueueSize)
+		}
+	}
+	if http := e.Http; http != nil {
+		if err := validateAddr(http.Addr); err != nil {
+			return fmt.Errorf("Http.Addr: %v", err)
+		}
+		if http.Timeout < 0 {
+			return fmt.Errorf("Http.Timeout: 不能小于0, 当前为%d", http.Timeout)
+		}
+		if http.RateLimit != nil {
+			if err := http.RateLimit.validate("Http.RateLimit"); err != nil {
+				return err
+			}
+		}
+	}
+	if ws := e.WebSocket; ws != nil {
+		if err := validateAddr(ws.Addr); err != nil {
+			return fmt.Errorf("WebSocket.Addr: %v", err)
+		}
+		if ws.WriteTimeout < 0 {
+			return fmt.Errorf("WebSocket.WriteTimeout: 不能小于0, 当前为%d", ws.WriteTimeout)
+		}
+		if ws.HeartbeatTimeout < 0 {
+			return fmt.Errorf("WebSocket.HeartbeatTimeout: 不能小于0, 当前为%d", ws.HeartbeatTimeout)
+		}
+		if ws.PingInterval < 0 {
+			return fmt.Errorf("WebSocket.PingInterval: 不能小于0, 当前为%d", ws.PingInterval)
+		}
+		if ws.PongTimeout < 0 {
+			return fmt.Errorf("WebSocket.PongTimeout: 不能小于0, 当前为%d", ws.PongTimeout)
+		}
+		if ws.MaxMessageSize < 0 {
+			return fmt.Errorf("WebSocket.MaxMessageSize: 不能小于0, 当前为%d", ws.MaxMessageSize)
+		}
+		if ws.SendQueueSize < 0 {
+			return fmt.Errorf("WebSocket.SendQueueSize: 不能小于0, 当前为%d", ws.SendQueueSize)
+		}
+		switch strings.ToLower(ws.Overflow) {
+		case "", "drop", "disconnect", "block":
+		default:
+			return fmt.Errorf("WebSocket.Overflow: 必须是drop, disconnect或block, 当前为%q", ws.Overflow)
+		}
+		if ws.OverflowTimeout < 0 {
+			return fmt.Errorf("WebSocket.OverflowTimeout: 不能小于0, 当前为%d", ws.OverflowTimeout)
+		}
+		if ws.SignKey != "" {
+			if seed, err := base64.StdEncoding.DecodeString(ws.SignKey); err != nil || len(seed) != 32 {
+				return fmt.Errorf("WebSocket.SignKey: 必须是base64编码的32字节")
+			}
+		} else if ws.Encrypt {
+			return fmt.Errorf("WebSocket.SignKey: Encrypt为true时不能为空")
+		}
+		if ws.RateLimit != nil {
+			if err := ws.RateLimit.validate("WebSocket.RateLimit"); err != nil {
+				return err
+			}
+		}
+	}
+	for i, md := range e.Modules {
+		if md == nil || md.Type == "" {
+			return fmt.Errorf("Modules[%d].Type: 不能为空", i)
+		}
+		if md.Addr != "" && !strings.HasPrefix(md.Addr, "unix:") {
+			if err := validateAddr(md.Addr); err != nil {
+				return fmt.Errorf("Modules[%d].Addr: %v", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// 校验监听地址, 格式为 host:port
+func validateAddr(addr string) error {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("监听地址格式有误 %q", addr)
+	}
+	return nil
+}
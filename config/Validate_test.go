@@ -0,0 +1,72 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSetDefaultsAndValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		conf  string
+		err   string                // 错误中应包含的内容, 为空时应该校验通过
+		check func(*AppConfig) bool // 填充默认值后的检查
+	}{
+		{"只有日志", `{}`, "", nil},
+		{"缺少日志", `{"Logger": null}`, "Logger: 缺少日志配置", nil},
+		{"日志级别", `{"Logger": {"file": {"level": 8}}}`, "Logger.File.Level", nil},
+		{"MySql默认值", `{"MySql": {"Dsn": "db"}}`, "", func(c *AppConfig) bool {
+			return *c.MySql.MaxOpenNum == 100 && *c.MySql.MaxIdleNum == 50 && *c.MySql.MaxLifetime == 600
+		}},
+		{"空闲连接数不超过最大连接数", `{"MySql": {"Dsn": "db", "MaxOpenNum": 20}}`, "", func(c *AppConfig) bool {
+			return *c.MySql.MaxOpenNum == 20 && *c.MySql.MaxIdleNum == 20
+		}},
+		{"显式的0保持不变", `{"MySql": {"Dsn": "db", "MaxOpenNum": 0, "MaxIdleNum": 0, "MaxLifetime": 0}}`, "", func(c *AppConfig) bool {
+			return *c.MySql.MaxOpenNum == 0 && *c.MySql.MaxIdleNum == 0 && *c.MySql.MaxLifetime == 0
+		}},
+		{"不限制连接数时使用默认的空闲连接数", `{"MySql": {"Dsn": "db", "MaxOpenNum": 0}}`, "", func(c *AppConfig) bool {
+			return *c.MySql.MaxIdleNum == 50
+		}},
+		{"空闲连接数超过最大连接数", `{"MySql": {"Dsn": "db", "MaxOpenNum": 20, "MaxIdleNum": 30}}`, "MySql.MaxIdleNum: 必须在0~MaxOpenNum(20)之间, 当前为30", nil},
+		{"最大连接数为负数", `{"MySql": {"Dsn": "db", "MaxOpenNum": -1}}`, "MySql.MaxOpenNum", nil},
+		{"缺少Dsn", `{"MySql": {}}`, "MySql.Dsn", nil},
+		{"DataBase默认值", `{"DataBase": {"DegradeCount": -1}}`, "", func(c *AppConfig) bool {
+			return c.DataBase.FlushInterval == 1000 && c.DataBase.QueueSize == 1024 && c.DataBase.DegradeCount == -1
+		}},
+		{"Http默认值", `{"Http": {}}`, "", func(c *AppConfig) bool {
+			return c.Http.Addr == ":8080" && c.Http.Timeout == 30
+		}},
+		{"Http地址", `{"Http": {"Addr": "8080"}}`, "Http.Addr", nil},
+		{"限流的cmd", `{"Http": {"RateLimit": {"Cmds": {"a": {"Rate": 1}}}}}`, "Http.RateLimit.Cmds", nil},
+		{"可信的代理", `{"Http": {"RateLimit": {"TrustedProxies": ["10.0.0.0/8", "::1", "cdn"]}}}`, "Http.RateLimit.TrustedProxies[2]", nil},
+		{"WebSocket默认值", `{"WebSocket": {"PingInterval": 5}}`, "", func(c *AppConfig) bool {
+			return c.WebSocket.Addr == ":8081" && c.WebSocket.PongTimeout == 10 && c.WebSocket.CompressThreshold == 1024
+		}},
+		{"发送队列满时的处理方式", `{"WebSocket": {"Overflow": "wait"}}`, "WebSocket.Overflow", nil},
+		{"加密时缺少签名私钥", `{"WebSocket": {"Encrypt": true}}`, "WebSocket.SignKey", nil},
+		{"签名私钥的长度", `{"WebSocket": {"SignKey": "YWJj"}}`, "WebSocket.SignKey", nil},
+		{"模块类型", `{"Modules": [{"Name": "a"}]}`, "Modules[0].Type", nil},
+		{"模块的unix地址", `{"Modules": [{"Type": "admin", "Addr": "unix:./a.sock"}]}`, "", nil},
+	}
+	for _, test := range tests {
+		conf := &AppConfig{Logger: &LoggerConfig{}}
+		if err := json.Unmarshal([]byte(test.conf), conf); err != nil {
+			t.Fatal(test.name, err)
+		}
+		conf.SetDefaults()
+		err := conf.Validate()
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: 错误不正确: %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if test.check != nil && !test.check(conf) {
+			buff, _ := json.Marshal(conf)
+			t.Errorf("%s: 默认值不正确: %s", test.name, buff)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package config
+
+type WebSocketConfig struct {
+	Addr              string
+	WriteTimeout      int              // 写超时(秒)
+	HeartbeatTimeout  int              // 多久没有收到消息断开连接(秒)
+	PingInterval      int              // 发送ping的间隔(秒), 为0时不发送
+	PongTimeout       int              // 发送ping后多久没有收到数据断开连接(秒)
+	MaxMessageSize    int              // 单条消息的最大长度(字节)
+	Codec             string           // 默认的编码方式, 如 json, msgpack
+	CompressThreshold int              // 消息体达到多少字节时压缩, 小于0时不压缩
+	Encrypt           bool             // 是否要求客户端先握手, 之后的消息都加密
+	SignKey           string           // 握手时签名的Ed25519私钥, base64编码的32字节种子, 握手时必须设置
+	RateLimit         *RateLimitConfig // 请求频率限制, 为空时不限制
+	SendQueueSize     int              // 每个连接的发送队列长度
+	Overflow          string           // 发送队列满时的处理方式: drop, disconnect, block
+	OverflowTimeout   int              // block时最长等待的时间(毫秒)
+}
+
+func (e *WebSocketConfig) setDefaults() {
+	if e.Addr == "" {
+		e.Addr = ":8081"
+	}
+	if e.WriteTimeout == 0 {
+		e.WriteTimeout = 60
+	}
+	if e.HeartbeatTimeout == 0 {
+		e.HeartbeatTimeout = 60
+	}
+	if e.CompressThreshold == 0 {
+		e.CompressThreshold = 1024
+	}
+	if e.PingInterval > 0 && e.PongTimeout == 0 {
+		e.PongTimeout = 10
+	}
+}
@@ -2,15 +2,124 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/team-zf/framework/utils"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
-func LoadConfig(filePath string) (conf *AppConfig, err error) {
+var (
+	// EnvPrefix 覆盖配置的环境变量前缀, 如 ZF_REDIS_ADDR 覆盖 Redis.Addr
+	EnvPrefix = "ZF_"
+)
+
+// LoadConfig 按顺序载入配置文件, 后面的文件覆盖前面的文件, 最后用环境变量覆盖
+// 文件中的 include 字段可以引入其它文件(相对于当前文件的目录), 引入的文件覆盖当前文件
+func LoadConfig(filePaths ...string) (conf *AppConfig, err error) {
+	if len(filePaths) == 0 {
+		return nil, fmt.Errorf("没有指定配置文件")
+	}
+	merged := make(map[string]interface{})
+	for _, filePath := range filePaths {
+		if err = loadLayer(filePath, merged, make(map[string]bool)); err != nil {
+			return nil, err
+		}
+	}
+
 	var bytes []byte
-	bytes, err = utils.ReadFile(filePath)
+	if bytes, err = json.Marshal(merged); err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(bytes, &conf); err != nil {
+		return nil, err
+	}
+	if conf.ignoredEnv, err = ApplyEnv(conf, EnvPrefix, os.Environ()); err != nil {
+		return nil, err
+	}
+	conf.SetDefaults()
+	return conf, nil
+}
+
+// 载入一个文件及其引入的文件, 合并到dst中
+func loadLayer(filePath string, dst map[string]interface{}, visiting map[string]bool) error {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return err
+	}
+	if visiting[abs] {
+		return fmt.Errorf("配置文件循环引入: %s", filePath)
+	}
+	visiting[abs] = true
+	defer delete(visiting, abs)
+
+	bytes, err := utils.ReadFile(filePath)
 	if err != nil {
-		return
+		return err
+	}
+	layer := make(map[string]interface{})
+	if err := json.Unmarshal(bytes, &layer); err != nil {
+		return fmt.Errorf("%s: %v", filePath, err)
+	}
+
+	var includes []string
+	if key, ok := findKey(layer, "include"); ok {
+		switch v := layer[key].(type) {
+		case string:
+			includes = []string{v}
+		case []interface{}:
+			for _, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("%s: include 必须是文件路径", filePath)
+				}
+				includes = append(includes, s)
+			}
+		default:
+			return fmt.Errorf("%s: include 必须是文件路径", filePath)
+		}
+		delete(layer, key)
+	}
+
+	mergeMap(dst, layer)
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(filePath), include)
+		}
+		if err := loadLayer(include, dst, visiting); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 把src合并到dst, 对象递归合并, 其它类型直接覆盖, 字段名不区分大小写
+func mergeMap(dst, src map[string]interface{}) {
+	for k, v := range src {
+		key, ok := findKey(dst, k)
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		dm, ok1 := dst[key].(map[string]interface{})
+		sm, ok2 := v.(map[string]interface{})
+		if ok1 && ok2 {
+			mergeMap(dm, sm)
+		} else {
+			dst[key] = v
+		}
+	}
+}
+
+// 不区分大小写查找字段名
+func findKey(m map[string]interface{}, name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k, true
+		}
 	}
-	err = json.Unmarshal(bytes, &conf)
-	return
+	return "", false
 }
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 在临时目录中写入配置文件, 返回目录
+func writeConfigs(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"base.json": `{
+			"Logger": {"file": {"level": 7}},
+			"Http": {"Addr": ":8080", "Timeout": 10},
+			"Settings": {"game": {"max_level": 60, "name": "base"}},
+			"include": "shared/db.json"
+		}`,
+		"shared/db.json": `{"MySql": {"Dsn": "db"}, "Settings": {"game": {"name": "shared"}}}`,
+		"prod.json":      `{"http": {"timeout": 30}, "Settings": {"game": {"max_level": 80}}}`,
+	})
+	defer os.RemoveAll(dir)
+
+	conf, err := LoadConfig(filepath.Join(dir, "base.json"), filepath.Join(dir, "prod.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 后面的文件覆盖前面的, 引入的文件覆盖当前文件, 对象递归合并且字段名不区分大小写
+	if conf.Http.Addr != ":8080" || conf.Http.Timeout != 30 || conf.MySql.Dsn != "db" {
+		t.Fatalf("合并结果不正确: %+v %+v", conf.Http, conf.MySql)
+	}
+	if conf.Settings.GetInt("game.max_level", 0) != 80 || conf.Settings.GetString("game.name", "") != "shared" {
+		t.Fatalf("Settings合并结果不正确: %v", conf.Settings)
+	}
+}
+
+func TestLoadConfigIncludeCycle(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"a.json": `{"include": ["b.json"]}`,
+		"b.json": `{"include": "c.json"}`,
+		"c.json": `{"include": "./a.json"}`,
+		"d.json": `{"include": 1}`,
+		"e.json": `{"include": "missing.json"}`,
+	})
+	defer os.RemoveAll(dir)
+
+	if _, err := LoadConfig(filepath.Join(dir, "a.json")); err == nil || !strings.Contains(err.Error(), "循环引入") {
+		t.Fatalf("循环引入时应该返回错误: %v", err)
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "d.json")); err == nil || !strings.Contains(err.Error(), "include") {
+		t.Fatalf("include格式错误时应该返回错误: %v", err)
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "e.json")); err == nil {
+		t.Fatal("引入的文件不存在时应该返回错误")
+	}
+	// 同一个文件被引入两次不是循环
+	dir2 := writeConfigs(t, map[string]string{
+		"a.json":   `{"include": ["b.json", "c.json"]}`,
+		"b.json":   `{"include": "log.json"}`,
+		"c.json":   `{"include": "log.json"}`,
+		"log.json": `{"Logger": {}}`,
+	})
+	defer os.RemoveAll(dir2)
+	if _, err := LoadConfig(filepath.Join(dir2, "a.json")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	conf := &AppConfig{
+		Http:     &HttpConfig{Addr: ":8080"},
+		Settings: Settings{"game": map[string]interface{}{"max_level": 60.0}},
+	}
+	ignored, err := ApplyEnv(conf, "ZF_", []string{
+		"ZF_HTTP_ADDR=:9000",
+		"zf_http_timeout=15",
+		"ZF_REDIS_MAX_ACTIVE=20",
+		"ZF_LOGGER_FILE_LEVEL=3",
+		"ZF_SETTINGS_GAME_MAX_LEVEL=99",
+		"ZF_SETTINGS_NEW_ITEMS=[1,2]",
+		"ZF_FOO=1",
+		"ZF_HTTP_NOPE=1",
+		"ZF_HTTP_ADDR_X=1",
+		"ZF_=1",
+		"OTHER_HTTP_ADDR=:1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ignored, []string{"ZF_FOO", "ZF_HTTP_ADDR_X", "ZF_HTTP_NOPE"}) {
+		t.Fatalf("忽略的变量不正确: %v", ignored)
+	}
+	if conf.Http.Addr != ":9000" || conf.Http.Timeout != 15 || conf.Redis.MaxActive != 20 || conf.Logger.File.Level != 3 {
+		t.Fatalf("覆盖结果不正确: %+v %+v %+v", conf.Http, conf.Redis, conf.Logger.File)
+	}
+	if conf.Settings.GetInt("game.max_level", 0) != 99 || len(conf.Settings.GetStringSlice("new.items", nil)) != 2 {
+		t.Fatalf("Settings覆盖结果不正确: %v", conf.Settings)
+	}
+
+	// 没有对应字段的变量不会创建出空的配置部分
+	conf = &AppConfig{}
+	ignored, err = ApplyEnv(conf, "ZF_", []string{"ZF_MYSQL_BOGUS=1"})
+	if !reflect.DeepEqual(ignored, []string{"ZF_MYSQL_BOGUS"}) || err != nil {
+		t.Fatalf("忽略的变量不正确: %v %v", ignored, err)
+	}
+	if _, err := ApplyEnv(conf, "ZF_", []string{"ZF_WEBSOCKET_MAX_MESSAGE_SIZE=abc"}); err == nil {
+		t.Fatal("值的格式错误时应该返回错误")
+	}
+	if conf.MySql != nil || conf.WebSocket != nil {
+		t.Fatalf("不应该创建配置部分: %+v %+v", conf.MySql, conf.WebSocket)
+	}
+	conf.Logger = &LoggerConfig{}
+	conf.SetDefaults()
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 字段存在但值的格式错误时返回错误
+	if _, err := ApplyEnv(conf, "ZF_", []string{"ZF_HTTP_TIMEOUT=abc"}); err == nil || !strings.Contains(err.Error(), "ZF_HTTP_TIMEOUT") {
+		t.Fatalf("值的格式错误时应该返回错误: %v", err)
+	}
+}
@@ -37,6 +37,12 @@ func Init(debug bool, logDir string, conf *config.LoggerConfig) {
 	}
 }
 
+// 调整日志级别, 文件日志同时调整
+func SetLevel(level int) {
+	getInstance().SetLevel(level)
+	getInstance().SetDeviceLevel(devices.DeviceFile, level)
+}
+
 func Close() {
 	getInstance().Close()
 }
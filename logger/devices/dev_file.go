@@ -64,7 +64,10 @@ func (e *FileDevice) Init(jsonConfig string) error {
 }
 
 func (e *FileDevice) WriteMsg(when time.Time, msg string, level int) error {
-	if level > e.Level || level < e.MinLevel {
+	e.RLock()
+	skip := level > e.Level || level < e.MinLevel
+	e.RUnlock()
+	if skip {
 		return nil
 	}
 	h, d := FormatTimeHeader(when)
@@ -94,6 +97,12 @@ func (e *FileDevice) WriteMsg(when time.Time, msg string, level int) error {
 	return err
 }
 
+func (e *FileDevice) SetLevel(v int) {
+	e.Lock()
+	e.Level = v
+	e.Unlock()
+}
+
 func (e *FileDevice) Destroy() {
 	e.writer.Flush()
 	e.file.Sync()
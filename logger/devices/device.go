@@ -24,6 +24,11 @@ type IDevice interface {
 	Flush()
 }
 
+// ILevelDevice 可在运行时调整级别的设备
+type ILevelDevice interface {
+	SetLevel(v int)
+}
+
 type DeviceWriter struct {
 	sync.Mutex
 	writer io.Writer
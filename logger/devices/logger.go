@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,7 +25,7 @@ var (
 
 type Logger struct {
 	lock        sync.Mutex
-	level       int
+	level       int32
 	init        bool
 	contentType string
 	devices     map[string]IDevice
@@ -32,7 +33,7 @@ type Logger struct {
 
 func NewLogger() *Logger {
 	log := new(Logger)
-	log.level = LevelDebug
+	log.level = int32(LevelDebug)
 	log.devices = make(map[string]IDevice)
 	log.setLogger(DeviceConsole)
 	return log
@@ -82,7 +83,26 @@ func (e *Logger) writeToLoggers(when time.Time, msg string, level int) {
 }
 
 func (e *Logger) SetLevel(v int) {
-	e.level = v
+	atomic.StoreInt32(&e.level, int32(v))
+}
+
+func (e *Logger) getLevel() int {
+	return int(atomic.LoadInt32(&e.level))
+}
+
+// SetDeviceLevel 调整指定设备的日志级别, 设备需实现ILevelDevice
+func (e *Logger) SetDeviceLevel(key string, v int) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	device, ok := e.devices[key]
+	if !ok {
+		return fmt.Errorf("logs: unknown devicename %q", key)
+	}
+	if d, ok := device.(ILevelDevice); ok {
+		d.SetLevel(v)
+		return nil
+	}
+	return fmt.Errorf("logs: device %q can not set level", key)
 }
 
 func (e *Logger) SetLogger(key string, configs ...string) error {
@@ -96,49 +116,49 @@ func (e *Logger) SetLogger(key string, configs ...string) error {
 }
 
 func (e *Logger) Debug(format string, v ...interface{}) {
-	if LevelDebug > e.level {
+	if LevelDebug > e.getLevel() {
 		return
 	}
 	e.writeMsg(LevelDebug, format, v...)
 }
 
 func (e *Logger) Info(format string, v ...interface{}) {
-	if LevelInformational > e.level {
+	if LevelInformational > e.getLevel() {
 		return
 	}
 	e.writeMsg(LevelInformational, format, v...)
 }
 
 func (e *Logger) Warn(format string, v ...interface{}) {
-	if LevelWarning > e.level {
+	if LevelWarning > e.getLevel() {
 		return
 	}
 	e.writeMsg(LevelWarning, format, v...)
 }
 
 func (e *Logger) Error(format string, v ...interface{}) {
-	if LevelError > e.level {
+	if LevelError > e.getLevel() {
 		return
 	}
 	e.writeMsg(LevelError, format, v...)
 }
 
 func (e *Logger) Alert(format string, v ...interface{}) {
-	if LevelAlert > e.level {
+	if LevelAlert > e.getLevel() {
 		return
 	}
 	e.writeMsg(LevelAlert, format, v...)
 }
 
 func (e *Logger) Critical(format string, v ...interface{}) {
-	if LevelCritical > e.level {
+	if LevelCritical > e.getLevel() {
 		return
 	}
 	e.writeMsg(LevelCritical, format, v...)
 }
 
 func (e *Logger) Notice(format string, v ...interface{}) {
-	if LevelNotice > e.level {
+	if LevelNotice > e.getLevel() {
 		return
 	}
 	e.writeMsg(LevelNotice, format, v...)
@@ -1,6 +1,11 @@
 package framework
 
 import (
+	// 注册框架内置的模块类型, 可以在server.json的Modules中声明
+	_ "github.com/team-zf/framework/DB"
+	_ "github.com/team-zf/framework/Network"
+	_ "github.com/team-zf/framework/admin"
+	_ "github.com/team-zf/framework/metrics"
 	"github.com/team-zf/framework/modules"
 )
 
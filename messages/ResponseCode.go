@@ -15,19 +15,24 @@ const (
 	RC_Success         uint32 = 200 // 成功
 	RC_User_STATUS_NOT uint32 = 201 // 用户状态错误
 	RC_NoPermission    uint32 = 202 // 没有权限
+	RC_DuplicateLogin  uint32 = 203 // 帐号在其它地方登录
+	RC_Kicked          uint32 = 204 // 被踢下线
 )
 
 const (
 	RC_Param_Error uint32 = 300 // 参数错误
+	RC_Seq_Error   uint32 = 301 // 请求序号错误, 乱序或重放
 )
 
 const (
-	RC_NotLogic uint32 = 400 // 没有逻辑处理它
-	RC_NotCmd   uint32 = 404 // 没有事件处理这个消息
+	RC_NotLogic    uint32 = 400 // 没有逻辑处理它
+	RC_NotCmd      uint32 = 404 // 没有事件处理这个消息
+	RC_RateLimited uint32 = 429 // 请求太频繁
 )
 
 const (
 	RC_LOGIC_ERROR   uint32 = 500 // 逻辑处理错误
 	RC_User_DB_Error uint32 = 501 // 数据库错误
 	RC_Config_Error  uint32 = 502 // 配置表错误
+	RC_Maintenance   uint32 = 503 // 服务器维护中
 )
@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"github.com/team-zf/framework/config"
+	"github.com/team-zf/framework/modules"
+)
+
+func init() {
+	modules.RegisterModuleType("metrics", newMetricsModuleFromConfig)
+}
+
+// Settings: Path 采集的路径
+func newMetricsModuleFromConfig(app modules.IApp, conf *config.ModuleConfig) (modules.IModule, error) {
+	result := NewMetricsModule()
+	if conf.Name != "" {
+		result.name = conf.Name
+	}
+	if conf.Addr != "" {
+		result.addr = conf.Addr
+	}
+	result.path = conf.Settings.GetString("Path", result.path)
+	return result, nil
+}
@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+var (
+	// DefBuckets 默认的直方图区间, 单位秒, 适合统计请求耗时
+	DefBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+)
+
+// 一组标签值对应的数据
+type series struct {
+	values []string
+	value  float64
+	counts []uint64 // 直方图每个区间的数量(不累计)
+	sum    float64
+	count  uint64
+}
+
+// 一个指标, 包含所有标签组合的数据
+type metric struct {
+	lock    sync.Mutex
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	fn      func() float64 // GaugeFunc的取值方法
+	series  map[string]*series
+}
+
+func newMetric(name, help, kind string, labels []string) *metric {
+	return &metric{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*series),
+	}
+}
+
+// 取得标签值对应的数据, 不存在时创建, 需要在锁内调用
+func (e *metric) get(values []string) *series {
+	if len(values) != len(e.labels) {
+		panic(fmt.Sprintf("metrics: %s 需要%d个标签值, 实际为%d个", e.name, len(e.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := e.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		if e.kind == typeHistogram {
+			s.counts = make([]uint64, len(e.buckets))
+		}
+		e.series[key] = s
+	}
+	return s
+}
+
+// 按标签值排序后的所有数据的副本
+func (e *metric) snapshot() []*series {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	result := make([]*series, 0, len(e.series))
+	for _, s := range e.series {
+		c := *s
+		c.counts = append([]uint64(nil), s.counts...)
+		result = append(result, &c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i].values, result[j].values
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	return result
+}
+
+// Counter 只增不减的累计值
+type Counter struct {
+	m *metric
+}
+
+// Inc 加1, values为标签值
+func (e *Counter) Inc(values ...string) {
+	e.Add(1, values...)
+}
+
+// Add 增加v, v不能为负数
+func (e *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: %s 不能减少", e.m.name))
+	}
+	e.m.lock.Lock()
+	e.m.get(values).value += v
+	e.m.lock.Unlock()
+}
+
+// Gauge 可增可减的当前值
+type Gauge struct {
+	m *metric
+}
+
+func (e *Gauge) Set(v float64, values ...string) {
+	e.m.lock.Lock()
+	e.m.get(values).value = v
+	e.m.lock.Unlock()
+}
+
+func (e *Gauge) Add(v float64, values ...string) {
+	e.m.lock.Lock()
+	e.m.get(values).value += v
+	e.m.lock.Unlock()
+}
+
+func (e *Gauge) Inc(values ...string) {
+	e.Add(1, values...)
+}
+
+func (e *Gauge) Dec(values ...string) {
+	e.Add(-1, values...)
+}
+
+// Histogram 按区间统计分布, 如请求耗时
+type Histogram struct {
+	m *metric
+}
+
+func (e *Histogram) Observe(v float64, values ...string) {
+	e.m.lock.Lock()
+	s := e.m.get(values)
+	for i, le := range e.m.buckets {
+		if v <= le {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += v
+	s.count++
+	e.m.lock.Unlock()
+}
+
+// 整理直方图区间: 排序, 去掉重复和+Inf(+Inf总是会输出)
+func normalizeBuckets(buckets []float64) []float64 {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	result := append([]float64(nil), buckets...)
+	sort.Float64s(result)
+	n := 0
+	for i, v := range result {
+		if math.IsInf(v, 1) || (i > 0 && v == result[i-1]) {
+			continue
+		}
+		result[n] = v
+		n++
+	}
+	return result[:n]
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"context"
+	"github.com/team-zf/framework/logger"
+	"github.com/team-zf/framework/modules"
+	"github.com/team-zf/framework/utils/threads"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// MetricsModule 以Prometheus文本格式输出指标的模块
+type MetricsModule struct {
+	name         string
+	addr         string
+	path         string
+	registry     *Registry
+	httpServer   *http.Server
+	fault        chan error
+	thgo         *threads.ThreadGo
+	requestCount int64 // 收到的抓取请求总数
+}
+
+func (e *MetricsModule) Init() error {
+	e.fault = make(chan error, 1)
+	handler := e.registry.Handler()
+	mux := http.NewServeMux()
+	mux.HandleFunc(e.path, func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&e.requestCount, 1)
+		handler.ServeHTTP(res, req)
+	})
+	e.httpServer = &http.Server{
+		Addr:    e.addr,
+		Handler: mux,
+	}
+	return nil
+}
+
+func (e *MetricsModule) Start() error {
+	ln, err := net.Listen("tcp", e.addr)
+	if err != nil {
+		return err
+	}
+	e.thgo.Go(func(ctx context.Context) {
+		logger.Notice("%s启动", e.name)
+		err := e.httpServer.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {
+			logger.Error("%s异常关闭, 原因: %+v", e.name, err)
+			e.fault <- err
+		}
+	})
+	return nil
+}
+
+func (e *MetricsModule) Stop() error {
+	err := e.httpServer.Close()
+	e.thgo.CloseWait()
+	logger.Notice("%s已停止", e.name)
+	return err
+}
+
+func (e *MetricsModule) Fault() <-chan error {
+	return e.fault
+}
+
+func (e *MetricsModule) Name() string {
+	return e.name
+}
+
+func (e *MetricsModule) Status() *modules.ModuleStatus {
+	return modules.NewModuleStatus(e.name).
+		Counter("requestCount", atomic.LoadInt64(&e.requestCount))
+}
+
+// GetRegistry 取得模块输出的注册表, 游戏逻辑可以在上面注册自己的指标
+func (e *MetricsModule) GetRegistry() *Registry {
+	return e.registry
+}
+
+func NewMetricsModule(opts ...modules.ModOptions) *MetricsModule {
+	result := &MetricsModule{
+		name:     "Metrics",
+		addr:     ":9100",
+		path:     "/metrics",
+		registry: Default,
+		thgo:     threads.NewThreadGo(),
+	}
+	for _, opt := range opts {
+		opt(result)
+	}
+	return result
+}
+
+func MetricsSetName(v string) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*MetricsModule).name = v
+	}
+}
+
+func MetricsSetAddr(v string) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*MetricsModule).addr = v
+	}
+}
+
+func MetricsSetPath(v string) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*MetricsModule).path = v
+	}
+}
+
+func MetricsSetRegistry(v *Registry) modules.ModOptions {
+	return func(mod modules.IModule) {
+		mod.(*MetricsModule).registry = v
+	}
+}
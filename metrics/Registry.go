@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// Default 默认的注册表, 框架内置的指标都注册在这里
+	Default = NewRegistry()
+)
+
+// Registry 指标注册表, 输出Prometheus的文本格式
+type Registry struct {
+	lock    sync.Mutex
+	metrics []*metric
+	names   map[string]*metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make([]*metric, 0),
+		names:   make(map[string]*metric),
+	}
+}
+
+// 注册指标, 同名同类型同标签的指标返回已注册的, 否则panic
+func (e *Registry) register(m *metric) *metric {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if old, ok := e.names[m.name]; ok {
+		if old.kind != m.kind || strings.Join(old.labels, ",") != strings.Join(m.labels, ",") || old.fn != nil || m.fn != nil {
+			panic(fmt.Sprintf("metrics: %s 重复注册", m.name))
+		}
+		return old
+	}
+	e.names[m.name] = m
+	e.metrics = append(e.metrics, m)
+	return m
+}
+
+// NewCounter 注册累计值, labels为标签名
+func (e *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{m: e.register(newMetric(name, help, typeCounter, labels))}
+}
+
+// NewGauge 注册当前值, labels为标签名
+func (e *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{m: e.register(newMetric(name, help, typeGauge, labels))}
+}
+
+// NewGaugeFunc 注册当前值, 每次输出时调用fn取值
+func (e *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	m := newMetric(name, help, typeGauge, nil)
+	m.fn = fn
+	e.register(m)
+}
+
+// NewHistogram 注册直方图, buckets为空时使用DefBuckets
+func (e *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	m := newMetric(name, help, typeHistogram, labels)
+	m.buckets = normalizeBuckets(buckets)
+	return &Histogram{m: e.register(m)}
+}
+
+// Unregister 删除指标
+func (e *Registry) Unregister(name string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if _, ok := e.names[name]; !ok {
+		return
+	}
+	delete(e.names, name)
+	for i, m := range e.metrics {
+		if m.name == name {
+			e.metrics = append(e.metrics[:i], e.metrics[i+1:]...)
+			break
+		}
+	}
+}
+
+// WriteText 按Prometheus文本格式输出所有指标
+func (e *Registry) WriteText(w io.Writer) error {
+	e.lock.Lock()
+	list := append([]*metric(nil), e.metrics...)
+	e.lock.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range list {
+		fmt.Fprintf(bw, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", m.name, m.kind)
+		if m.fn != nil {
+			fmt.Fprintf(bw, "%s %s\n", m.name, formatFloat(m.fn()))
+			continue
+		}
+		for _, s := range m.snapshot() {
+			if m.kind != typeHistogram {
+				fmt.Fprintf(bw, "%s%s %s\n", m.name, formatLabels(m.labels, s.values, "", ""), formatFloat(s.value))
+				continue
+			}
+			var total uint64
+			for i, le := range m.buckets {
+				total += s.counts[i]
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", m.name, formatLabels(m.labels, s.values, "le", formatFloat(le)), total)
+			}
+			fmt.Fprintf(bw, "%s_bucket%s %d\n", m.name, formatLabels(m.labels, s.values, "le", "+Inf"), s.count)
+			fmt.Fprintf(bw, "%s_sum%s %s\n", m.name, formatLabels(m.labels, s.values, "", ""), formatFloat(s.sum))
+			fmt.Fprintf(bw, "%s_count%s %d\n", m.name, formatLabels(m.labels, s.values, "", ""), s.count)
+		}
+	}
+	return bw.Flush()
+}
+
+// Handler 输出指标的http接口, 可以挂到任意的http服务上
+func (e *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		e.WriteText(res)
+	})
+}
+
+// 生成 {a="1",b="2"}, extraName不为空时追加在最后
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	items := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		items = append(items, fmt.Sprintf(`%s="%s"`, name, escapeLabel(values[i])))
+	}
+	if extraName != "" {
+		items = append(items, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+	return "{" + strings.Join(items, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+// NewCounter 在默认注册表中注册累计值
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+// NewGauge 在默认注册表中注册当前值
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return Default.NewGauge(name, help, labels...)
+}
+
+// NewGaugeFunc 在默认注册表中注册当前值
+func NewGaugeFunc(name, help string, fn func() float64) {
+	Default.NewGaugeFunc(name, help, fn)
+}
+
+// NewHistogram 在默认注册表中注册直方图
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
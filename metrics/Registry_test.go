@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.NewCounter("test_requests_total", "请求总数", "cmd")
+	c.Inc("101")
+	c.Add(2, "100")
+	c.Inc("101")
+	g := reg.NewGauge("test_online", "在线\n人数")
+	g.Set(3)
+	g.Dec()
+	reg.NewGaugeFunc("test_func", "取值方法", func() float64 { return 1.5 })
+	h := reg.NewHistogram("test_duration_seconds", "耗时", []float64{0.1, 1}, "cmd")
+	h.Observe(0.05, `a"b`)
+	h.Observe(0.5, `a"b`)
+	h.Observe(5, `a"b`)
+
+	buff := &bytes.Buffer{}
+	if err := reg.WriteText(buff); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		`# HELP test_requests_total 请求总数`,
+		`# TYPE test_requests_total counter`,
+		`test_requests_total{cmd="100"} 2`,
+		`test_requests_total{cmd="101"} 2`,
+		`# HELP test_online 在线\n人数`,
+		`# TYPE test_online gauge`,
+		`test_online 2`,
+		`# HELP test_func 取值方法`,
+		`# TYPE test_func gauge`,
+		`test_func 1.5`,
+		`# HELP test_duration_seconds 耗时`,
+		`# TYPE test_duration_seconds histogram`,
+		`test_duration_seconds_bucket{cmd="a\"b",le="0.1"} 1`,
+		`test_duration_seconds_bucket{cmd="a\"b",le="1"} 2`,
+		`test_duration_seconds_bucket{cmd="a\"b",le="+Inf"} 3`,
+		`test_duration_seconds_sum{cmd="a\"b"} 5.55`,
+		`test_duration_seconds_count{cmd="a\"b"} 3`,
+		``,
+	}, "\n")
+	if buff.String() != want {
+		t.Errorf("输出不正确:\n%s\n期望:\n%s", buff.String(), want)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	reg := NewRegistry()
+	a := reg.NewCounter("test_total", "", "cmd")
+	b := reg.NewCounter("test_total", "", "cmd")
+	a.Inc("1")
+	b.Inc("1")
+	buff := &bytes.Buffer{}
+	reg.WriteText(buff)
+	if !strings.Contains(buff.String(), `test_total{cmd="1"} 2`) {
+		t.Errorf("同名指标应当共用: %s", buff.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("不同类型的同名指标应当panic")
+		}
+	}()
+	reg.NewGauge("test_total", "", "cmd")
+}
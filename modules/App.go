@@ -1,45 +1,67 @@
 package modules
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"github.com/team-zf/framework/config"
 	"github.com/team-zf/framework/logger"
 	"github.com/team-zf/framework/tables"
 	"github.com/team-zf/framework/utils"
+	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
 type App struct {
 	PStatusTime               time.Duration // 打印状态的时间
+	stopTimeout               time.Duration // 排空阶段的总时限
+	flushTimeout              time.Duration // 停止阶段的总时限, 不受排空阶段用掉的时间影响
+	moduleStopTimeout         time.Duration // 单个模块排空或停止的时限
 	config                    *config.AppConfig
+	configLock                sync.RWMutex
+	reloadLock                sync.Mutex
 	debug                     bool
 	parse                     bool
-	confPath                  string
+	confPaths                 []string // 配置文件, 后面的覆盖前面的
 	logDir                    string
 	tableDir                  string
-	started                   bool
+	tablePoll                 time.Duration // 轮询数据表目录的间隔, 为0时不轮询
+	started                   int32         // 是否已经启动, 状态监听的协程会读取
+	maintenance               int32         // 是否处于维护模式
+	ready                     int32         // 是否可以接收流量
+	tablesLoaded              int32         // 数据表是否已经载入, 设置了数据表目录时没有载入不能接收流量
+	statusAddr                string        // 状态监听地址, 为空时不启动
+	statusServer              *http.Server
 	modules                   []IModule
+	moduleOptions             map[string][]ModOptions // 配置文件中声明的模块的参数
+	faults                    chan error
+	settingLock               sync.Mutex
+	settingWatchers           map[string][]func(app IApp, old, value interface{})
 	event_ConfigurationLoaded func(app IApp, conf *config.AppConfig)
-	event_TablesLoaded        func(app IApp)
+	event_TablesLoaded        func(app IApp, summary *tables.Summary)
 	event_Startup             func(app IApp)
 	event_Stoped              func(app IApp)
 }
 
 func (e *App) Init() IApp {
 	if e.parse {
-		confPath := flag.String("c", "", "配置文件路径")
+		confPaths := &pathList{}
+		flag.Var(confPaths, "c", "配置文件路径, 可重复指定或用逗号分隔, 后面的覆盖前面的")
 		logDir := flag.String("l", "", "日志文件目录")
 		tableDir := flag.String("t", "", "数据表目录")
 		debug := flag.String("d", "", "是否启动调试模式")
 		flag.Parse()
 
-		if confPath != nil && *confPath != "" {
-			e.confPath = *confPath
+		if len(*confPaths) > 0 {
+			e.confPaths = *confPaths
 		}
 		if logDir != nil && *logDir != "" {
 			e.logDir = *logDir
@@ -56,85 +78,344 @@ func (e *App) Init() IApp {
 		utils.Mkdir(e.logDir)
 	}
 	e.loadConfig()
-	//e.loadTables()
+	if err := e.buildModules(); err != nil {
+		panic(err)
+	}
+	if err := e.loadTables(); err != nil {
+		logger.Error("数据表载入失败, 原因: %+v", err)
+		e.exit(1)
+	}
 	return e
 }
 
 func (e *App) loadConfig() {
-	if _, err := os.Open(e.confPath); err != nil {
-		panic(fmt.Sprintf("未找到服务器配置文件 %s", e.confPath))
-	}
-
-	if conf, err := config.LoadConfig(e.confPath); err == nil {
-		e.config = conf
-	} else {
+	conf, err := e.readConfig()
+	if err != nil {
 		panic(err)
 	}
+	e.config = conf
 
 	logger.Init(e.debug, e.logDir, e.config.Logger)
+	warnIgnoredEnv(conf)
 	if e.event_ConfigurationLoaded != nil {
 		e.event_ConfigurationLoaded(e, e.config)
 	}
 }
 
-func (e *App) loadTables() {
+// 读取并校验配置文件
+func (e *App) readConfig() (*config.AppConfig, error) {
+	for _, path := range e.confPaths {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("未找到服务器配置文件 %s", path)
+		}
+	}
+	conf, err := config.LoadConfig(e.confPaths...)
+	if err != nil {
+		return nil, err
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// 记录没有对应配置字段的环境变量
+func warnIgnoredEnv(conf *config.AppConfig) {
+	for _, name := range conf.IgnoredEnv() {
+		logger.Warn("环境变量%s没有对应的配置字段, 已忽略", name)
+	}
+}
+
+// ReloadConfig 重新读取配置文件, 并通知实现了IModuleReload的模块
+// 配置文件有误时返回错误, 继续使用原来的配置
+func (e *App) ReloadConfig() error {
+	e.reloadLock.Lock()
+	defer e.reloadLock.Unlock()
+
+	conf, err := e.readConfig()
+	if err != nil {
+		logger.Error("重新载入配置失败, 继续使用原来的配置, 原因: %+v", err)
+		return err
+	}
+	warnIgnoredEnv(conf)
+	old := e.GetConfig()
+	e.configLock.Lock()
+	e.config = conf
+	e.configLock.Unlock()
+
+	if file := conf.Logger.File; file != nil {
+		if old.Logger.File == nil || old.Logger.File.Level != file.Level {
+			logger.SetLevel(file.Level)
+		}
+	}
+	for i, md := range e.modules {
+		if reload, ok := md.(IModuleReload); ok {
+			if err := reload.Reload(conf); err != nil {
+				logger.Error("%s重新载入配置失败, 原因: %+v", moduleName(md, i), err)
+			}
+		}
+	}
+	e.notifySettings(old.Settings, conf.Settings)
+	if e.event_ConfigurationLoaded != nil {
+		e.event_ConfigurationLoaded(e, conf)
+	}
+	logger.Notice("配置重新载入完成: %s", strings.Join(e.confPaths, ", "))
+	return nil
+}
+
+// 启动时载入数据表, 没有数据表时不能工作, 出错时中断启动
+func (e *App) loadTables() error {
 	if e.tableDir == "" {
-		return
+		return nil
 	}
-	tables.LoadTables(e.tableDir, e.config.Table)
+	summary, err := tables.ReloadTables(e.tableDir, e.config.Table)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&e.tablesLoaded, 1)
 	if e.event_TablesLoaded != nil {
-		e.event_TablesLoaded(e)
+		e.event_TablesLoaded(e, summary)
+	}
+	return nil
+}
+
+// ReloadTables 重新载入数据表目录, 全部校验通过后整体替换
+// 出错时返回错误, 继续使用原来的数据表
+func (e *App) ReloadTables() error {
+	if e.tableDir == "" {
+		return fmt.Errorf("没有设置数据表目录")
+	}
+	summary, err := tables.ReloadTables(e.tableDir, e.GetConfig().Table)
+	if err != nil {
+		logger.Error("重新载入数据表失败, 继续使用原来的数据表, 原因: %+v", err)
+		return err
+	}
+	if e.event_TablesLoaded != nil {
+		e.event_TablesLoaded(e, summary)
+	}
+	return nil
+}
+
+// 定时检查数据表目录, 有文件变化时重新载入
+func (e *App) pollTables(done <-chan struct{}) {
+	stamp, _ := tables.Stamp(e.tableDir, e.GetConfig().Table)
+	t := time.NewTicker(e.tablePoll)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			next, err := tables.Stamp(e.tableDir, e.GetConfig().Table)
+			if err != nil || next == stamp {
+				continue
+			}
+			// 载入失败时保留旧的标记, 文件修正后会再次尝试
+			if e.ReloadTables() == nil {
+				stamp = next
+			}
+		}
+	}
+}
+
+// 按依赖关系排序并初始化所有模块
+func (e *App) initModules() error {
+	mds, err := SortModules(e.modules)
+	if err != nil {
+		return err
+	}
+	e.modules = mds
+	for _, md := range e.modules {
+		if m, ok := md.(IModuleApp); ok {
+			m.SetApp(e)
+		}
+	}
+	for i, md := range e.modules {
+		if err := md.Init(); err != nil {
+			e.releaseModules(e.modules[:i])
+			return fmt.Errorf("%s初始化失败: %v", moduleName(md, i), err)
+		}
+	}
+	return nil
+}
+
+// 按相反的顺序释放已经初始化的模块, 如关闭数据库连接
+func (e *App) releaseModules(mds []IModule) {
+	for i := len(mds) - 1; i >= 0; i-- {
+		release, ok := mds[i].(IModuleRelease)
+		if !ok {
+			continue
+		}
+		if err := release.Release(); err != nil {
+			logger.Error("%s释放失败, 原因: %+v", moduleName(mds[i], i), err)
+		}
+	}
+}
+
+// 按顺序启动所有模块, 遇到失败时停止已经启动的模块
+func (e *App) startModules() error {
+	for i, md := range e.modules {
+		if err := md.Start(); err != nil {
+			e.stopModules(e.modules[:i])
+			return fmt.Errorf("%s启动失败: %v", moduleName(md, i), err)
+		}
+		if fault, ok := md.(IModuleFault); ok {
+			name := moduleName(md, i)
+			go func(ch <-chan error) {
+				if err, ok := <-ch; ok && err != nil {
+					select {
+					case e.faults <- fmt.Errorf("%s运行失败: %v", name, err):
+					default:
+					}
+				}
+			}(fault.Fault())
+		}
+	}
+	return nil
+}
+
+// 按相反的顺序停止模块, 分两个阶段:
+// 1. 实现了IModuleDrain的模块停止接收新的工作, 并处理完已有的工作, 总时限为stopTimeout
+// 2. 所有模块调用Stop, 如DataBaseModule写入缓存的数据, 总时限为flushTimeout
+// 两个阶段的时限分开计算, 排空超时不会挤占写入数据的时间
+// 每个模块受moduleStopTimeout限制, 超时的模块记录日志后跳过
+func (e *App) stopModules(mds []IModule) {
+	e.drainModules(mds)
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.flushTimeout)
+	defer cancel()
+	for i := len(mds) - 1; i >= 0; i-- {
+		md := mds[i]
+		name := moduleName(md, i)
+		err := e.runWithTimeout(ctx, name, "停止", func(ctx context.Context) error {
+			return md.Stop()
+		})
+		if err != nil {
+			logger.Error("%s停止失败, 原因: %+v", name, err)
+		}
+	}
+}
+
+func (e *App) drainModules(mds []IModule) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.stopTimeout)
+	defer cancel()
+	for i := len(mds) - 1; i >= 0; i-- {
+		md, ok := mds[i].(IModuleDrain)
+		if !ok {
+			continue
+		}
+		name := moduleName(mds[i], i)
+		err := e.runWithTimeout(ctx, name, "排空", func(ctx context.Context) error {
+			return md.Drain(ctx)
+		})
+		if err != nil {
+			logger.Error("%s排空失败, 原因: %+v", name, err)
+		}
 	}
 }
 
+// 在单个模块的时限内执行fn, 超时后不再等待fn返回
+func (e *App) runWithTimeout(parent context.Context, name, action string, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(parent, e.moduleStopTimeout)
+	defer cancel()
+
+	begin := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		logger.Warn("%s%s超时, 已等待%v, 不再等待", name, action, time.Since(begin))
+		return ctx.Err()
+	}
+}
+
+// 刷新日志并以指定的代码退出进程
+func (e *App) exit(code int) {
+	logger.Close()
+	os.Exit(code)
+}
+
 func (e *App) Run(mds ...IModule) IApp {
 	if len(mds) > 0 {
 		e.AddModule(mds...)
 	}
-
-	e.started = true
-	for _, md := range e.modules {
-		md.Start()
+	if err := e.initModules(); err != nil {
+		logger.Error("模块初始化失败, 原因: %+v", err)
+		e.exit(1)
+	}
+	if err := e.startModules(); err != nil {
+		logger.Error("模块启动失败, 原因: %+v", err)
+		e.exit(1)
 	}
+	if err := e.startStatusServer(); err != nil {
+		logger.Error("状态监听启动失败, 原因: %+v", err)
+		e.stopModules(e.modules)
+		e.exit(1)
+	}
+
+	atomic.StoreInt32(&e.started, 1)
 	if e.event_Startup != nil {
 		e.event_Startup(e)
 	}
+	e.setReady(true)
+	done := make(chan struct{})
+	defer close(done)
+	if e.tableDir != "" && e.tablePoll > 0 {
+		go e.pollTables(done)
+	}
 
 	// 这里要柱塞等关闭
+	code := 0
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 	t := time.NewTicker(e.PStatusTime)
 	defer t.Stop()
 Pstatus:
 	for {
 		select {
-		case <-c: //退出
+		case sig := <-c:
+			if sig == syscall.SIGHUP { //重新载入配置和数据表
+				e.ReloadConfig()
+				if e.tableDir != "" {
+					e.ReloadTables()
+				}
+				continue
+			}
+			//退出
+			break Pstatus
+		case err := <-e.faults: //模块运行出错, 退出
+			logger.Error("模块异常, 服务器即将关闭, 原因: %+v", err)
+			code = 1
 			break Pstatus
 		case <-t.C:
 			var ps string
-			for _, md := range e.modules {
-				ps += md.PrintStatus()
+			for _, status := range e.Status() {
+				ps += status.String()
 			}
 			logger.Notice(ps)
 		}
 	}
-	e.started = false
+	e.setReady(false)
+	atomic.StoreInt32(&e.started, 0)
+	e.stopStatusServer()
 	if e.event_Stoped != nil {
 		e.event_Stoped(e)
 	}
-	for i := len(e.modules) - 1; i >= 0; i-- {
-		md := e.modules[i]
-		md.Stop()
+	e.stopModules(e.modules)
+	if code != 0 {
+		e.exit(code)
 	}
 	logger.Close()
 	return e
 }
 
+// AddModule 添加模块, 模块会在Run时按依赖关系初始化
 func (e *App) AddModule(mds ...IModule) IApp {
 	e.modules = append(e.modules, mds...)
-	for _, md := range mds {
-		md.Init()
-	}
 	return e
 }
 
@@ -142,10 +423,46 @@ func (e *App) OnConfigurationLoaded(fn func(app IApp, conf *config.AppConfig)) {
 	e.event_ConfigurationLoaded = fn
 }
 
-func (e *App) OnTablesLoaded(fn func(app IApp)) {
+func (e *App) OnTablesLoaded(fn func(app IApp, summary *tables.Summary)) {
 	e.event_TablesLoaded = fn
 }
 
+// OnSettingChanged 订阅Settings中的字段, 重新载入配置后值发生变化时回调
+// path 为 "a.b.c" 形式的路径, 字段被删除时value为nil
+func (e *App) OnSettingChanged(path string, fn func(app IApp, old, value interface{})) {
+	e.settingLock.Lock()
+	defer e.settingLock.Unlock()
+	if e.settingWatchers == nil {
+		e.settingWatchers = make(map[string][]func(app IApp, old, value interface{}))
+	}
+	e.settingWatchers[path] = append(e.settingWatchers[path], fn)
+}
+
+// 对比新旧Settings, 通知订阅了发生变化的字段的回调
+func (e *App) notifySettings(old, settings config.Settings) {
+	e.settingLock.Lock()
+	paths := make([]string, 0, len(e.settingWatchers))
+	watchers := make(map[string][]func(app IApp, old, value interface{}))
+	for path, fns := range e.settingWatchers {
+		paths = append(paths, path)
+		watchers[path] = fns
+	}
+	e.settingLock.Unlock()
+
+	sort.Strings(paths)
+	for _, path := range paths {
+		prev, _ := old.Get(path)
+		value, _ := settings.Get(path)
+		if reflect.DeepEqual(prev, value) {
+			continue
+		}
+		logger.Notice("配置Settings.%s已变化", path)
+		for _, fn := range watchers[path] {
+			fn(e, prev, value)
+		}
+	}
+}
+
 func (e *App) OnStartup(fn func(app IApp)) {
 	e.event_Startup = fn
 }
@@ -155,21 +472,52 @@ func (e *App) OnStoped(fn func(app IApp)) {
 }
 
 func (e *App) GetConfig() *config.AppConfig {
+	e.configLock.RLock()
+	defer e.configLock.RUnlock()
 	return e.config
 }
 
+// Modules 按启动顺序排列的所有模块
+func (e *App) Modules() []IModule {
+	return e.modules
+}
+
+// SetMaintenance 开启或关闭维护模式, 并通知实现了IModuleMaintenance的模块
+func (e *App) SetMaintenance(v bool) {
+	var flag int32
+	if v {
+		flag = 1
+	}
+	if atomic.SwapInt32(&e.maintenance, flag) == flag {
+		return
+	}
+	for _, md := range e.modules {
+		if m, ok := md.(IModuleMaintenance); ok {
+			m.SetMaintenance(v)
+		}
+	}
+	logger.Notice("维护模式: %v", v)
+}
+
+func (e *App) Maintenance() bool {
+	return atomic.LoadInt32(&e.maintenance) == 1
+}
+
 func (e *App) Debug() bool {
 	return e.debug
 }
 
 func NewApp(opts ...AppOptions) *App {
 	result := &App{
-		PStatusTime: 10 * time.Second,
-		logDir:      "./logs",
-		confPath:    "./server.json",
-		tableDir:    "",
-		modules:     make([]IModule, 0),
-		started:     false,
+		PStatusTime:       10 * time.Second,
+		stopTimeout:       30 * time.Second,
+		flushTimeout:      30 * time.Second,
+		moduleStopTimeout: 10 * time.Second,
+		logDir:            "./logs",
+		confPaths:         []string{"./server.json"},
+		tableDir:          "",
+		modules:           make([]IModule, 0),
+		faults:            make(chan error, 1),
 	}
 	for _, opt := range opts {
 		opt(result)
@@ -203,7 +551,14 @@ func AppSetTableDir(v string) AppOptions {
 
 func AppSetConfPath(v string) AppOptions {
 	return func(app IApp) {
-		app.(*App).confPath = v
+		app.(*App).confPaths = []string{v}
+	}
+}
+
+// 设置多个配置文件, 后面的覆盖前面的
+func AppSetConfPaths(v ...string) AppOptions {
+	return func(app IApp) {
+		app.(*App).confPaths = v
 	}
 }
 
@@ -212,3 +567,55 @@ func AppSetPStatusTime(v time.Duration) AppOptions {
 		app.(*App).PStatusTime = v
 	}
 }
+
+// 设置关闭服务器时排空阶段的总时限
+func AppSetStopTimeout(v time.Duration) AppOptions {
+	return func(app IApp) {
+		app.(*App).stopTimeout = v
+	}
+}
+
+// 设置关闭服务器时停止阶段(如写入缓存的数据)的总时限
+func AppSetFlushTimeout(v time.Duration) AppOptions {
+	return func(app IApp) {
+		app.(*App).flushTimeout = v
+	}
+}
+
+// 设置单个模块排空或停止的时限
+func AppSetModuleStopTimeout(v time.Duration) AppOptions {
+	return func(app IApp) {
+		app.(*App).moduleStopTimeout = v
+	}
+}
+
+// 设置轮询数据表目录的间隔, 为0时不轮询
+func AppSetTablePoll(v time.Duration) AppOptions {
+	return func(app IApp) {
+		app.(*App).tablePoll = v
+	}
+}
+
+// 设置状态监听地址, 如 127.0.0.1:9090
+// 提供 /status(模块状态) /healthz(存活检查) /readyz(就绪检查)
+func AppSetStatusAddr(v string) AppOptions {
+	return func(app IApp) {
+		app.(*App).statusAddr = v
+	}
+}
+
+// 命令行中的路径列表, 支持重复指定和逗号分隔
+type pathList []string
+
+func (e *pathList) String() string {
+	return strings.Join(*e, ",")
+}
+
+func (e *pathList) Set(v string) error {
+	for _, path := range strings.Split(v, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			*e = append(*e, path)
+		}
+	}
+	return nil
+}
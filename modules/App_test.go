@@ -0,0 +1,102 @@
+package modules
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 重新载入配置后, 值发生变化的订阅者收到新旧值
+func TestOnSettingChanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "server.json")
+	write := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"Logger": {}, "Settings": {"game": {"max_level": 60, "name": "a"}, "old": 1}}`)
+	app := &App{confPaths: []string{path}}
+	if app.config, err = app.readConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	type change struct{ old, value interface{} }
+	changes := make(map[string][]change)
+	for _, key := range []string{"game.max_level", "game.name", "old", "new"} {
+		key := key
+		app.OnSettingChanged(key, func(app IApp, old, value interface{}) {
+			changes[key] = append(changes[key], change{old, value})
+		})
+	}
+
+	write(`{"Logger": {}, "Settings": {"game": {"max_level": 80, "name": "a"}, "new": "x"}}`)
+	if err := app.ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]change{
+		"game.max_level": {{60.0, 80.0}},
+		"old":            {{1.0, nil}},
+		"new":            {{nil, "x"}},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("通知的内容不正确: %v", changes)
+	}
+	if app.GetConfig().Settings.GetInt("game.max_level", 0) != 80 {
+		t.Fatal("没有使用新的配置")
+	}
+
+	// 配置有误时继续使用原来的配置, 不通知
+	write(`{"Logger": {}, "Settings": `)
+	if err := app.ReloadConfig(); err == nil {
+		t.Fatal("配置有误时应该返回错误")
+	}
+	if len(changes["game.max_level"]) != 1 || app.GetConfig().Settings.GetInt("game.max_level", 0) != 80 {
+		t.Fatal("配置有误时不应该替换配置")
+	}
+}
+
+type lifecycleTestModule struct {
+	graphTestModule
+	initErr error
+	log     *[]string
+}
+
+func (e *lifecycleTestModule) Init() error {
+	*e.log = append(*e.log, "init "+e.name)
+	return e.initErr
+}
+
+func (e *lifecycleTestModule) Release() error {
+	*e.log = append(*e.log, "release "+e.name)
+	return nil
+}
+
+// 初始化失败时, 按相反的顺序释放已经初始化的模块
+func TestInitModulesRelease(t *testing.T) {
+	var log []string
+	app := &App{}
+	for _, name := range []string{"db", "cache", "http", "ws"} {
+		md := &lifecycleTestModule{log: &log}
+		md.name = name
+		if name == "http" {
+			md.initErr = errors.New("端口错误")
+		}
+		app.modules = append(app.modules, md)
+	}
+	if err := app.initModules(); err == nil || !strings.Contains(err.Error(), "http初始化失败") {
+		t.Fatalf("错误不正确: %v", err)
+	}
+	want := []string{"init db", "init cache", "init http", "release cache", "release db"}
+	if !reflect.DeepEqual(log, want) {
+		t.Fatalf("调用顺序不正确: %v", log)
+	}
+}
@@ -1,19 +1,80 @@
 package modules
 
 import (
+	"context"
 	"github.com/team-zf/framework/config"
+	"github.com/team-zf/framework/tables"
+	"net/http"
 )
 
 // IModule 模块接口
 type IModule interface {
 	// Init 初始化
-	Init()
-	// Start 启动
-	Start()
+	Init() error
+	// Start 启动, 返回错误时App会停止已经启动的模块并退出
+	Start() error
 	// Stop 停止
-	Stop()
-	// PrintStatus 打印状态
-	PrintStatus() string
+	Stop() error
+	// Status 模块状态
+	Status() *ModuleStatus
+}
+
+// IModuleName 模块名称(可选实现), 用于被其它模块依赖
+type IModuleName interface {
+	Name() string
+}
+
+// IModuleFault 模块运行期间的异常(可选实现)
+// 启动之后发生的致命错误(如监听端口断开)写入该通道, App收到后会关闭服务器
+type IModuleFault interface {
+	Fault() <-chan error
+}
+
+// IModuleDrain 优雅关闭(可选实现)
+// 关闭服务器时, App会先调用所有模块的Drain, 再调用Stop
+type IModuleDrain interface {
+	// Drain 停止接收新的工作, 并在ctx结束前处理完已有的工作
+	Drain(ctx context.Context) error
+}
+
+// IModuleRelease 释放Init中申请的资源(可选实现)
+// 后面的模块初始化失败时, 已经初始化但还没有启动的模块不会调用Stop, 而是按相反的顺序调用Release
+type IModuleRelease interface {
+	Release() error
+}
+
+// IModuleReload 重新载入配置(可选实现)
+// 收到SIGHUP或调用App.ReloadConfig时, 模块会收到校验通过的新配置
+type IModuleReload interface {
+	Reload(conf *config.AppConfig) error
+}
+
+// IModuleDepends 模块依赖(可选实现)
+// App会先初始化和启动被依赖的模块, 并按相反的顺序停止
+type IModuleDepends interface {
+	// DependsOn 依赖的模块名称
+	DependsOn() []string
+}
+
+// IModuleApp 需要访问App的模块(可选实现), 在Init之前调用
+type IModuleApp interface {
+	SetApp(app IApp)
+}
+
+// IModuleMaintenance 维护模式(可选实现)
+// 维护模式下网络模块不再处理请求, 直接返回维护中
+type IModuleMaintenance interface {
+	SetMaintenance(v bool)
+}
+
+// IModuleKick 断开指定的连接(可选实现), 找到并断开时返回true
+type IModuleKick interface {
+	Kick(id string) bool
+}
+
+// IModuleFlush 立刻写入缓存的数据(可选实现)
+type IModuleFlush interface {
+	Flush() error
 }
 
 type IApp interface {
@@ -21,10 +82,22 @@ type IApp interface {
 	Run(mds ...IModule) IApp
 	AddModule(mds ...IModule) IApp
 	OnConfigurationLoaded(fn func(app IApp, conf *config.AppConfig))
-	OnTablesLoaded(fn func(app IApp))
+	OnTablesLoaded(fn func(app IApp, summary *tables.Summary))
+	OnSettingChanged(path string, fn func(app IApp, old, value interface{}))
 	OnStartup(fn func(app IApp))
 	OnStoped(fn func(app IApp))
+	ReloadConfig() error
+	ReloadTables() error
 	GetConfig() *config.AppConfig
+	Status() []*ModuleStatus
+	Modules() []IModule
+	GetModule(name string) IModule
+	Ready() bool
+	Health() (string, []*ModuleHealth)
+	HealthzHandler() http.Handler
+	ReadyzHandler() http.Handler
+	SetMaintenance(v bool)
+	Maintenance() bool
 	Debug() bool
 }
 
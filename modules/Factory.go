@@ -0,0 +1,87 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/team-zf/framework/config"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ModuleFactory 根据配置文件中的声明创建模块
+type ModuleFactory func(app IApp, conf *config.ModuleConfig) (IModule, error)
+
+var (
+	factoryLock sync.RWMutex
+	factories   = make(map[string]ModuleFactory)
+)
+
+// RegisterModuleType 注册模块类型, 一般在包的init()中调用, 类型名称不区分大小写
+func RegisterModuleType(typ string, fn ModuleFactory) {
+	factoryLock.Lock()
+	defer factoryLock.Unlock()
+	key := strings.ToLower(typ)
+	if _, ok := factories[key]; ok {
+		panic(fmt.Errorf("模块类型%s重复注册", typ))
+	}
+	factories[key] = fn
+}
+
+// ModuleTypes 所有注册过的模块类型
+func ModuleTypes() []string {
+	factoryLock.RLock()
+	defer factoryLock.RUnlock()
+	result := make([]string, 0, len(factories))
+	for typ := range factories {
+		result = append(result, typ)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// NewModule 按配置创建模块
+func NewModule(app IApp, conf *config.ModuleConfig) (IModule, error) {
+	factoryLock.RLock()
+	fn, ok := factories[strings.ToLower(conf.Type)]
+	factoryLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("未知的模块类型%s, 已注册的类型: %s", conf.Type, strings.Join(ModuleTypes(), ", "))
+	}
+	return fn(app, conf)
+}
+
+// 创建配置文件中声明的模块, 并应用代码中为这些模块设置的参数
+func (e *App) buildModules() error {
+	for i, conf := range e.config.Modules {
+		md, err := NewModule(e, conf)
+		if err != nil {
+			return fmt.Errorf("Modules[%d]: %v", i, err)
+		}
+		for _, opt := range e.moduleOptions[moduleName(md, len(e.modules))] {
+			opt(md)
+		}
+		e.modules = append(e.modules, md)
+	}
+	return nil
+}
+
+// GetModule 按名称查找模块
+func (e *App) GetModule(name string) IModule {
+	for _, md := range e.modules {
+		if named, ok := md.(IModuleName); ok && named.Name() == name {
+			return md
+		}
+	}
+	return nil
+}
+
+// 为配置文件中声明的模块设置参数, 如路由等无法写在配置中的内容
+func AppSetModuleOptions(name string, opts ...ModOptions) AppOptions {
+	return func(app IApp) {
+		e := app.(*App)
+		if e.moduleOptions == nil {
+			e.moduleOptions = make(map[string][]ModOptions)
+		}
+		e.moduleOptions[name] = append(e.moduleOptions[name], opts...)
+	}
+}
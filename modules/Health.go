@@ -0,0 +1,105 @@
+package modules
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// 模块的健康状态
+const (
+	HealthOK       = "ok"       // 正常
+	HealthDegraded = "degraded" // 可以工作, 但有异常(如数据库保存一直失败)
+	HealthDown     = "down"     // 不能工作
+)
+
+// IModuleHealth 健康检查(可选实现)
+type IModuleHealth interface {
+	// Health 返回健康状态(HealthOK/HealthDegraded/HealthDown)和原因
+	Health() (state string, message string)
+}
+
+// ModuleHealth 模块的健康状态
+type ModuleHealth struct {
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Message string `json:"message,omitempty"`
+}
+
+// 健康检查接口的返回内容
+type healthResponse struct {
+	State   string          `json:"state"`
+	Ready   bool            `json:"ready"`
+	Modules []*ModuleHealth `json:"modules"`
+}
+
+// Ready 是否可以接收流量: 所有模块启动完成且没有开始关闭, 设置了数据表目录时数据表已经载入
+func (e *App) Ready() bool {
+	if e.tableDir != "" && atomic.LoadInt32(&e.tablesLoaded) == 0 {
+		return false
+	}
+	return atomic.LoadInt32(&e.ready) == 1
+}
+
+func (e *App) setReady(v bool) {
+	if v {
+		atomic.StoreInt32(&e.ready, 1)
+	} else {
+		atomic.StoreInt32(&e.ready, 0)
+	}
+}
+
+// Health 取得所有模块的健康状态, 整体状态取最差的模块
+func (e *App) Health() (string, []*ModuleHealth) {
+	state := HealthOK
+	result := make([]*ModuleHealth, 0, len(e.modules))
+	for i, md := range e.modules {
+		health, ok := md.(IModuleHealth)
+		if !ok {
+			continue
+		}
+		item := &ModuleHealth{Name: moduleName(md, i)}
+		item.State, item.Message = health.Health()
+		switch item.State {
+		case HealthDown:
+			state = HealthDown
+		case HealthDegraded:
+			if state == HealthOK {
+				state = HealthDegraded
+			}
+		}
+		result = append(result, item)
+	}
+	return state, result
+}
+
+// HealthzHandler 存活检查, 有模块不能工作时返回503
+func (e *App) HealthzHandler() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		state, mds := e.Health()
+		code := http.StatusOK
+		if state == HealthDown {
+			code = http.StatusServiceUnavailable
+		}
+		writeHealth(res, code, &healthResponse{State: state, Ready: e.Ready(), Modules: mds})
+	})
+}
+
+// ReadyzHandler 就绪检查, 未启动完成/正在关闭/有模块不能工作时返回503
+func (e *App) ReadyzHandler() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		state, mds := e.Health()
+		ready := e.Ready()
+		code := http.StatusOK
+		if !ready || state == HealthDown {
+			code = http.StatusServiceUnavailable
+		}
+		writeHealth(res, code, &healthResponse{State: state, Ready: ready, Modules: mds})
+	})
+}
+
+func writeHealth(res http.ResponseWriter, code int, body *healthResponse) {
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.WriteHeader(code)
+	json.NewEncoder(res).Encode(body)
+}
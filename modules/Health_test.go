@@ -0,0 +1,38 @@
+package modules
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/team-zf/framework/config"
+)
+
+// 设置了数据表目录时, 数据表载入成功后才可以接收流量
+func TestReadyTables(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tables")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	app := &App{tableDir: dir, config: &config.AppConfig{}}
+	app.setReady(true)
+
+	ioutil.WriteFile(path.Join(dir, "wx_bad.json"), []byte(`[[1, "x"]]`), 0644)
+	if err := app.loadTables(); err == nil {
+		t.Fatal("数据表格式错误时应该返回错误")
+	}
+	if app.Ready() {
+		t.Fatal("数据表没有载入时不应该可以接收流量")
+	}
+
+	os.Remove(path.Join(dir, "wx_bad.json"))
+	ioutil.WriteFile(path.Join(dir, "wx_hero.json"), []byte(`[[1, [["name", "a"]]]]`), 0644)
+	if err := app.loadTables(); err != nil {
+		t.Fatal(err)
+	}
+	if !app.Ready() {
+		t.Fatal("数据表载入后应该可以接收流量")
+	}
+}
@@ -0,0 +1,117 @@
+package modules
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 取得模块名称, 未实现IModuleName的模块用序号代替
+func moduleName(md IModule, idx int) string {
+	if named, ok := md.(IModuleName); ok && named.Name() != "" {
+		return named.Name()
+	}
+	return fmt.Sprintf("#%d(%T)", idx, md)
+}
+
+// SortModules 按依赖关系对模块进行拓扑排序
+// 没有依赖关系的模块保持添加时的先后顺序;
+// 依赖的模块不存在、名称重复或存在循环依赖时返回错误
+func SortModules(mds []IModule) ([]IModule, error) {
+	names := make([]string, len(mds))
+	index := make(map[string]int)
+	for i, md := range mds {
+		names[i] = moduleName(md, i)
+		if _, ok := md.(IModuleName); !ok {
+			continue
+		}
+		if j, ok := index[names[i]]; ok {
+			return nil, fmt.Errorf("模块名称重复: %s (第%d个与第%d个模块)", names[i], j, i)
+		}
+		index[names[i]] = i
+	}
+
+	// 建立依赖图, edges[i] 为依赖第i个模块的模块
+	degree := make([]int, len(mds))
+	edges := make([][]int, len(mds))
+	for i, md := range mds {
+		depends, ok := md.(IModuleDepends)
+		if !ok {
+			continue
+		}
+		for _, dep := range depends.DependsOn() {
+			j, ok := index[dep]
+			if !ok {
+				return nil, fmt.Errorf("模块 %s 依赖的模块 %s 不存在", names[i], dep)
+			}
+			if j == i {
+				return nil, fmt.Errorf("模块 %s 不能依赖自己", names[i])
+			}
+			edges[j] = append(edges[j], i)
+			degree[i]++
+		}
+	}
+
+	// 每次取出添加顺序最靠前且没有未启动依赖的模块
+	result := make([]IModule, 0, len(mds))
+	done := make([]bool, len(mds))
+	for len(result) < len(mds) {
+		next := -1
+		for i := range mds {
+			if !done[i] && degree[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return nil, fmt.Errorf("模块存在循环依赖: %s", strings.Join(findCycle(names, edges, done), " -> "))
+		}
+		done[next] = true
+		result = append(result, mds[next])
+		for _, i := range edges[next] {
+			degree[i]--
+		}
+	}
+	return result, nil
+}
+
+// 在未排序的模块中找出一条依赖环, 用于错误提示
+func findCycle(names []string, edges [][]int, done []bool) []string {
+	// 0:未访问 1:访问中 2:已完成
+	state := make([]int, len(names))
+	stack := make([]int, 0)
+	var cycle []string
+	var visit func(i int) bool
+	visit = func(i int) bool {
+		state[i] = 1
+		stack = append(stack, i)
+		for _, j := range edges[i] {
+			if done[j] {
+				continue
+			}
+			if state[j] == 1 {
+				for k := len(stack) - 1; k >= 0; k-- {
+					if stack[k] == j {
+						// 按"依赖于"的方向输出
+						cycle = append(cycle, names[j])
+						for n := len(stack) - 1; n >= k; n-- {
+							cycle = append(cycle, names[stack[n]])
+						}
+						return true
+					}
+				}
+			}
+			if state[j] == 0 && visit(j) {
+				return true
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = 2
+		return false
+	}
+	for i := range names {
+		if !done[i] && state[i] == 0 && visit(i) {
+			break
+		}
+	}
+	return cycle
+}
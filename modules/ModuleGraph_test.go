@@ -0,0 +1,85 @@
+package modules
+
+import (
+	"strings"
+	"testing"
+)
+
+type graphTestModule struct {
+	name    string
+	depends []string
+}
+
+func (e *graphTestModule) Init() error           { return nil }
+func (e *graphTestModule) Start() error          { return nil }
+func (e *graphTestModule) Stop() error           { return nil }
+func (e *graphTestModule) Status() *ModuleStatus { return nil }
+func (e *graphTestModule) Name() string          { return e.name }
+func (e *graphTestModule) DependsOn() []string   { return e.depends }
+
+// 格式: "名称:依赖,依赖"
+func graphTestModules(specs ...string) []IModule {
+	result := make([]IModule, len(specs))
+	for i, spec := range specs {
+		md := &graphTestModule{}
+		parts := strings.SplitN(spec, ":", 2)
+		md.name = parts[0]
+		if len(parts) > 1 && parts[1] != "" {
+			md.depends = strings.Split(parts[1], ",")
+		}
+		result[i] = md
+	}
+	return result
+}
+
+func TestSortModules(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []string
+		order string // 排序后的名称
+		err   string // 错误中应包含的内容
+	}{
+		{"没有依赖保持顺序", []string{"c", "a", "b"}, "c a b", ""},
+		{"依赖在后面", []string{"http:db", "db", "log"}, "db http log", ""},
+		{"多层依赖", []string{"ws:http", "http:db", "db"}, "db http ws", ""},
+		{"稳定排序", []string{"b:x", "a:x", "x", "c"}, "x b a c", ""},
+		{"缺少依赖", []string{"http:db"}, "", "模块 http 依赖的模块 db 不存在"},
+		{"依赖自己", []string{"db:db"}, "", "模块 db 不能依赖自己"},
+		{"名称重复", []string{"db", "db"}, "", "模块名称重复: db"},
+		{"两个模块循环", []string{"a:b", "b:a"}, "", "模块存在循环依赖: a -> b -> a"},
+		{"三个模块循环", []string{"x", "a:c", "b:a", "c:b,x"}, "", "模块存在循环依赖: a -> c -> b -> a"},
+	}
+	for _, test := range tests {
+		result, err := SortModules(graphTestModules(test.specs...))
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: 错误不正确: %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		names := make([]string, len(result))
+		for i, md := range result {
+			names[i] = md.(*graphTestModule).name
+		}
+		if order := strings.Join(names, " "); order != test.order {
+			t.Errorf("%s: 顺序不正确: %s", test.name, order)
+		}
+	}
+}
+
+func TestFindCycle(t *testing.T) {
+	// edges[i] 为依赖第i个模块的模块: b依赖a, c依赖b, a依赖c, d依赖a
+	names := []string{"a", "b", "c", "d"}
+	edges := [][]int{{1, 3}, {2}, {0}, nil}
+	if cycle := strings.Join(findCycle(names, edges, make([]bool, 4)), " -> "); cycle != "a -> c -> b -> a" {
+		t.Fatalf("依赖环不正确: %s", cycle)
+	}
+	// 已排序的模块不在环中
+	if cycle := findCycle(names, edges, []bool{true, false, false, false}); len(cycle) != 0 {
+		t.Fatalf("不应该有依赖环: %v", cycle)
+	}
+}
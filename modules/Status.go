@@ -0,0 +1,54 @@
+package modules
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ModuleStatus 模块状态
+type ModuleStatus struct {
+	Name     string             `json:"name"`
+	Counters map[string]int64   `json:"counters"` // 累计值, 如请求总数
+	Gauges   map[string]float64 `json:"gauges"`   // 当前值, 如在线人数
+	keys     []string           // 添加的顺序, 用于输出日志
+}
+
+func NewModuleStatus(name string) *ModuleStatus {
+	return &ModuleStatus{
+		Name:     name,
+		Counters: make(map[string]int64),
+		Gauges:   make(map[string]float64),
+		keys:     make([]string, 0),
+	}
+}
+
+// Counter 设置累计值
+func (e *ModuleStatus) Counter(key string, v int64) *ModuleStatus {
+	if _, ok := e.Counters[key]; !ok {
+		e.keys = append(e.keys, key)
+	}
+	e.Counters[key] = v
+	return e
+}
+
+// Gauge 设置当前值
+func (e *ModuleStatus) Gauge(key string, v float64) *ModuleStatus {
+	if _, ok := e.Gauges[key]; !ok {
+		e.keys = append(e.keys, key)
+	}
+	e.Gauges[key] = v
+	return e
+}
+
+// String 输出给日志看的格式
+func (e *ModuleStatus) String() string {
+	items := make([]string, 0, len(e.keys))
+	for _, key := range e.keys {
+		if v, ok := e.Gauges[key]; ok {
+			items = append(items, fmt.Sprintf("%s=%v", key, v))
+		} else {
+			items = append(items, fmt.Sprintf("%s=%d", key, e.Counters[key]))
+		}
+	}
+	return fmt.Sprintf("\r\n\t\t%s的状态:\t%s", e.Name, strings.Join(items, " "))
+}
@@ -0,0 +1,67 @@
+package modules
+
+import (
+	"encoding/json"
+	"github.com/team-zf/framework/logger"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// 状态接口的返回内容
+type statusResponse struct {
+	Time    int64           `json:"time"`
+	Started bool            `json:"started"`
+	Modules []*ModuleStatus `json:"modules"`
+}
+
+// Status 取得所有模块的状态
+func (e *App) Status() []*ModuleStatus {
+	result := make([]*ModuleStatus, 0, len(e.modules))
+	for _, md := range e.modules {
+		result = append(result, md.Status())
+	}
+	return result
+}
+
+// StatusHandler 以JSON格式输出所有模块的状态, 可以挂到任意的http服务上
+func (e *App) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(&statusResponse{
+			Time:    time.Now().Unix(),
+			Started: atomic.LoadInt32(&e.started) == 1,
+			Modules: e.Status(),
+		})
+	})
+}
+
+// 启动状态监听, 地址为空时不启动
+func (e *App) startStatusServer() error {
+	if e.statusAddr == "" {
+		return nil
+	}
+	ln, err := net.Listen("tcp", e.statusAddr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/status", e.StatusHandler())
+	mux.Handle("/healthz", e.HealthzHandler())
+	mux.Handle("/readyz", e.ReadyzHandler())
+	e.statusServer = &http.Server{Handler: mux}
+	go func() {
+		logger.Notice("状态监听启动: %s", e.statusAddr)
+		if err := e.statusServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Error("状态监听异常关闭, 原因: %+v", err)
+		}
+	}()
+	return nil
+}
+
+func (e *App) stopStatusServer() {
+	if e.statusServer != nil {
+		e.statusServer.Close()
+	}
+}
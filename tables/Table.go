@@ -1,40 +1,86 @@
 package tables
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"github.com/team-zf/framework/config"
 	"github.com/team-zf/framework/logger"
 	"github.com/team-zf/framework/utils"
+	"github.com/team-zf/framework/utils/threads"
 	"io/ioutil"
 	"os"
 	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
 )
 
 var (
-	tables map[string]*Table
+	current    atomic.Value // 当前使用的数据表 *tableSet
+	reloadLock sync.Mutex
 )
 
 func init() {
-	tables = make(map[string]*Table)
+	current.Store(&tableSet{
+		tables: make(map[string]*Table),
+		hashes: make(map[string]string),
+	})
 }
 
-func LoadTables(dir string, conf *config.TableConfig) {
-	s, err := os.Stat(dir)
-	if err != nil || !s.IsDir() {
-		return
-	}
+// 一次完整载入的数据表, 载入后不再修改, 整体替换
+type tableSet struct {
+	tables map[string]*Table
+	hashes map[string]string // 文件内容的摘要, 用于判断数据表是否有变化
+}
 
-	prefix := "wx_"
+// Summary 数据表载入结果
+type Summary struct {
+	Added     []string // 新增的数据表
+	Removed   []string // 删除的数据表
+	Changed   []string // 内容有变化的数据表
+	Unchanged []string // 内容没有变化的数据表
+}
+
+// HasChanged 是否有数据表发生变化
+func (e *Summary) HasChanged() bool {
+	return len(e.Added)+len(e.Removed)+len(e.Changed) > 0
+}
+
+func (e *Summary) String() string {
+	return fmt.Sprintf("新增: %v; 删除: %v; 变化: %v; 未变化: %d个",
+		e.Added, e.Removed, e.Changed, len(e.Unchanged))
+}
+
+// 取得文件名的前缀和后缀
+func tableAffix(conf *config.TableConfig) (prefix, suffix string) {
+	prefix = "wx_"
 	if conf != nil && conf.Prefix != "" {
 		prefix = conf.Prefix
 	}
-	suffix := ".json"
+	suffix = ".json"
 	if conf != nil && conf.Suffix != "" {
 		suffix = conf.Suffix
 	}
+	return
+}
 
-	files, _ := ioutil.ReadDir(dir)
-	logger.Notice("开始载入数据表.")
+// 列出目录中所有的数据表文件, 返回 数据表名->文件
+func tableFiles(dir string, conf *config.TableConfig) (map[string]os.FileInfo, error) {
+	s, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !s.IsDir() {
+		return nil, fmt.Errorf("%s不是目录", dir)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix, suffix := tableAffix(conf)
+	result := make(map[string]os.FileInfo)
 	for _, file := range files {
 		fileName := utils.NewString(file.Name())
 		v1 := file.IsDir() == false
@@ -44,30 +90,115 @@ func LoadTables(dir string, conf *config.TableConfig) {
 			x := len(prefix)
 			y := len(fileName.ToString()) - len(suffix)
 			name := fileName.Substr(x, y).ToString()
-			table, _ := loadTableFile(path.Join(dir, file.Name()))
-			tables[name] = table
-			logger.Notice("File: %s; key: %s", file.Name(), name)
+			result[name] = file
 		}
 	}
-	logger.Notice("数据表载入完成.")
+	return result, nil
+}
+
+// LoadTables 载入目录中的数据表, 出错时记录日志并保留原来的数据表
+func LoadTables(dir string, conf *config.TableConfig) {
+	if _, err := ReloadTables(dir, conf); err != nil {
+		logger.Error("数据表载入失败, 原因: %+v", err)
+	}
 }
 
-func loadTableFile(filePath string) (*Table, error) {
-	file, err := os.Open(filePath)
+// ReloadTables 在后台载入目录中所有的数据表, 全部载入并校验成功后整体替换
+// 任何一个文件出错都会返回错误, 此时继续使用原来的数据表
+// 替换是原子的, GetTable不会读到只载入了一部分的数据表
+func ReloadTables(dir string, conf *config.TableConfig) (*Summary, error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	files, err := tableFiles(dir, conf)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-	bytes, _ := ioutil.ReadAll(file)
+
+	logger.Notice("开始载入数据表.")
+	next := &tableSet{
+		tables: make(map[string]*Table),
+		hashes: make(map[string]string),
+	}
+	for name, file := range files {
+		table, hash, err := loadTableFile(path.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("File: %s; Error: %v", file.Name(), err)
+		}
+		next.tables[name] = table
+		next.hashes[name] = hash
+		logger.Notice("File: %s; key: %s", file.Name(), name)
+	}
+
+	prev := current.Load().(*tableSet)
+	summary := new(Summary)
+	for name, hash := range next.hashes {
+		if old, ok := prev.hashes[name]; !ok {
+			summary.Added = append(summary.Added, name)
+		} else if old != hash {
+			summary.Changed = append(summary.Changed, name)
+		} else {
+			summary.Unchanged = append(summary.Unchanged, name)
+		}
+	}
+	for name := range prev.hashes {
+		if _, ok := next.hashes[name]; !ok {
+			summary.Removed = append(summary.Removed, name)
+		}
+	}
+	sort.Strings(summary.Added)
+	sort.Strings(summary.Removed)
+	sort.Strings(summary.Changed)
+	sort.Strings(summary.Unchanged)
+
+	current.Store(next)
+	logger.Notice("数据表载入完成. %s", summary)
+	return summary, nil
+}
+
+// Stamp 目录中数据表文件的标记(文件名,大小,修改时间), 用于轮询检查是否有文件变化
+func Stamp(dir string, conf *config.TableConfig) (string, error) {
+	files, err := tableFiles(dir, conf)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := md5.New()
+	for _, name := range names {
+		file := files[name]
+		fmt.Fprintf(h, "%s|%d|%d\n", file.Name(), file.Size(), file.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func loadTableFile(filePath string) (table *Table, hash string, err error) {
+	bytes, err := utils.ReadFile(filePath)
+	if err != nil {
+		return nil, "", err
+	}
 	datas := make([]interface{}, 0)
 	if err := json.Unmarshal(bytes, &datas); err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	// 数据格式不对时NewTable会panic, 转成错误返回
+	threads.Try(func() {
+		table = NewTable(datas)
+	}, func(e error) {
+		err = fmt.Errorf("数据格式错误: %v", e)
+	})
+	if err != nil {
+		return nil, "", err
 	}
-	return NewTable(datas), nil
+	sum := md5.Sum(bytes)
+	return table, hex.EncodeToString(sum[:]), nil
 }
 
 func GetTable(name string) *Table {
-	return tables[name]
+	return current.Load().(*tableSet).tables[name]
 }
 
 type Table struct {
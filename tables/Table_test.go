@@ -0,0 +1,65 @@
+package tables
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func writeTable(t *testing.T, dir, name, content string) {
+	if err := ioutil.WriteFile(path.Join(dir, "wx_"+name+".json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadTables(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tables")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTable(t, dir, "hero", `[[1, [["name", "a"]]]]`)
+	writeTable(t, dir, "skill", `[[1, [["atk", 10]]]]`)
+	writeTable(t, dir, "item", `[[1, [["price", 5]]]]`)
+	if _, err := ReloadTables(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	hero := GetTable("hero")
+
+	writeTable(t, dir, "skill", `[[1, [["atk", 20]]]]`)
+	writeTable(t, dir, "buff", `[[1, [["time", 3]]]]`)
+	os.Remove(path.Join(dir, "wx_item.json"))
+	summary, err := ReloadTables(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Summary{
+		Added:     []string{"buff"},
+		Removed:   []string{"item"},
+		Changed:   []string{"skill"},
+		Unchanged: []string{"hero"},
+	}
+	if !reflect.DeepEqual(summary, want) {
+		t.Fatalf("载入结果不正确: %s", summary)
+	}
+	if GetTable("item") != nil || GetTable("skill").ByKey(1).GetDataInt("atk") != 20 {
+		t.Fatal("没有替换为新的数据表")
+	}
+	if GetTable("hero") == hero {
+		t.Fatal("每次载入都应该是新的数据表")
+	}
+
+	// 任何一个文件出错时整体保留原来的数据表
+	skill := GetTable("skill")
+	writeTable(t, dir, "hero", `[[1, [["name", "b"]]]]`)
+	writeTable(t, dir, "bad", `[[1, "x"]]`)
+	if _, err := ReloadTables(dir, nil); err == nil {
+		t.Fatal("数据格式错误时应该返回错误")
+	}
+	if GetTable("skill") != skill || GetTable("hero").ByKey(1).GetDataToString("name") != "a" || GetTable("bad") != nil {
+		t.Fatal("载入失败时不应该替换数据表")
+	}
+}
@@ -0,0 +1,21 @@
+package threads
+
+import (
+	"context"
+	"sync"
+)
+
+// 等待WaitGroup完成, ctx结束时提前返回ctx的错误
+func WaitContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}